@@ -0,0 +1,166 @@
+package barcode
+
+import (
+	"errors"
+	"strings"
+)
+
+/* every product barcode is stored and looked up as a GTIN-14, so a UPC-A,
+its EAN-13 form and a zero padded scan of the same item all hit the same row */
+
+type Format string
+
+const (
+	EAN8   Format = "EAN-8"
+	EAN13  Format = "EAN-13"
+	UPCA   Format = "UPC-A"
+	UPCE   Format = "UPC-E"
+	GTIN14 Format = "GTIN-14"
+)
+
+var (
+	ErrEmpty       = errors.New("barcode is empty")
+	ErrNonNumeric  = errors.New("barcode must contain only digits")
+	ErrLength      = errors.New("barcode must be 8, 12, 13 or 14 digits long")
+	ErrCheckDigit  = errors.New("barcode check digit is invalid")
+	ErrInvalidUPCE = errors.New("barcode is not a valid UPC-E code")
+)
+
+// Code is a validated barcode together with the format it was scanned in
+type Code struct {
+	GTIN   string `json:"gtin"`
+	Format Format `json:"format"`
+	// some 8 digit codes are valid both as EAN-8 and as UPC-E, GTIN is the
+	// EAN-8 reading and Alternate the UPC-E one
+	Alternate string `json:"alternate,omitempty"`
+}
+
+// GTINs lists the GTIN-14s the scan may stand for, the likelier one first
+func (c Code) GTINs() []string {
+	if c.Alternate == "" {
+		return []string{c.GTIN}
+	}
+	return []string{c.GTIN, c.Alternate}
+}
+
+// Parse validates a scanned barcode and returns its canonical GTIN-14 form
+func Parse(raw string) (Code, error) {
+	digits := clean(raw)
+	if digits == "" {
+		return Code{}, ErrEmpty
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Code{}, ErrNonNumeric
+		}
+	}
+
+	switch len(digits) {
+	case 8:
+		// the digits alone don't say which of the two it is. an EAN-8 check
+		// digit covers the 8 digits and a UPC-E one the expanded UPC-A, so a
+		// code can pass both, and then it is looked up both ways
+		upca, upceErr := ExpandUPCE(digits)
+		if validCheckDigit(digits) {
+			code := Code{GTIN: pad(digits), Format: EAN8}
+			if upceErr == nil {
+				code.Alternate = pad(upca)
+			}
+			return code, nil
+		}
+		if upceErr == nil {
+			return Code{GTIN: pad(upca), Format: UPCE}, nil
+		}
+		return Code{}, ErrCheckDigit
+	case 12:
+		if !validCheckDigit(digits) {
+			return Code{}, ErrCheckDigit
+		}
+		return Code{GTIN: pad(digits), Format: UPCA}, nil
+	case 13:
+		if !validCheckDigit(digits) {
+			return Code{}, ErrCheckDigit
+		}
+		// an EAN-13 with a leading zero is just a UPC-A
+		if digits[0] == '0' {
+			return Code{GTIN: pad(digits), Format: UPCA}, nil
+		}
+		return Code{GTIN: pad(digits), Format: EAN13}, nil
+	case 14:
+		if !validCheckDigit(digits) {
+			return Code{}, ErrCheckDigit
+		}
+		return Code{GTIN: digits, Format: GTIN14}, nil
+	default:
+		return Code{}, ErrLength
+	}
+}
+
+// Normalize is Parse for callers that store a barcode and only need its
+// canonical GTIN-14, lookups should try every form in Code.GTINs
+func Normalize(raw string) (string, error) {
+	code, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	return code.GTIN, nil
+}
+
+// ExpandUPCE turns an 8 digit UPC-E code into its 12 digit UPC-A equivalent
+func ExpandUPCE(upce string) (string, error) {
+	if len(upce) != 8 || (upce[0] != '0' && upce[0] != '1') {
+		return "", ErrInvalidUPCE
+	}
+
+	ns, d, check := upce[0:1], upce[1:7], upce[7:8]
+	var body string
+	switch d[5] {
+	case '0', '1', '2':
+		body = d[0:2] + d[5:6] + "0000" + d[2:5]
+	case '3':
+		body = d[0:3] + "00000" + d[3:5]
+	case '4':
+		body = d[0:4] + "00000" + d[4:5]
+	default:
+		body = d[0:5] + "0000" + d[5:6]
+	}
+
+	upca := ns + body + check
+	if !validCheckDigit(upca) {
+		return "", ErrInvalidUPCE
+	}
+	return upca, nil
+}
+
+// CheckDigit computes the GS1 mod-10 check digit for the given payload (without check digit)
+func CheckDigit(payload string) int {
+	sum := 0
+	// weights alternate 3,1,3,... starting from the rightmost payload digit
+	for i := len(payload) - 1; i >= 0; i-- {
+		n := int(payload[i] - '0')
+		if (len(payload)-1-i)%2 == 0 {
+			n *= 3
+		}
+		sum += n
+	}
+	return (10 - sum%10) % 10
+}
+
+func validCheckDigit(digits string) bool {
+	n := len(digits)
+	return CheckDigit(digits[:n-1]) == int(digits[n-1]-'0')
+}
+
+// scanners and users often send spaces or dashes between digit groups
+func clean(raw string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+}
+
+func pad(digits string) string {
+	return strings.Repeat("0", 14-len(digits)) + digits
+}
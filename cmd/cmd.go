@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"ecoscan.com/config"
+	"ecoscan.com/logic"
+	"github.com/jmoiron/sqlx"
+)
+
+/* the ecoscan command tree. every command loads the same config.GetConfig,
+and Execute turns the outcome into the exit code: 0 on success, 1 when the
+command failed and 2 when it was called wrongly */
+
+// command is a node of the tree, either runnable or a group of subcommands
+type command struct {
+	name    string
+	args    string // usage after the command's path, e.g. "[-lang bn] <barcode>"
+	summary string
+	run     func(c *command, args []string) error
+	sub     []*command
+
+	path string // e.g. "import products", filled in by init
+}
+
+var root = &command{name: "ecoscan", sub: []*command{
+	{name: "serve", summary: "run the HTTP API, the default without a command", run: serve},
+	{name: "migrate", summary: "apply, revert or list schema migrations", sub: []*command{
+		{name: "up", summary: "apply every pending migration", run: migrateUp},
+		{name: "down", args: "[-steps n]", summary: "revert the newest migrations", run: migrateDown},
+		{name: "status", summary: "list migrations and when they were applied", run: migrateStatus},
+	}},
+	{name: "seed", summary: "load the demo product catalog", run: seed},
+	{name: "import", summary: "bulk load data", sub: []*command{
+		{name: "products", args: "[-format csv|json] <file|->", summary: "create or update products from a CSV or JSON file", run: importProducts},
+	}},
+	{name: "rescore", args: "[-all]", summary: "recompute stale product scores", run: rescore},
+	{name: "product", summary: "inspect products", sub: []*command{
+		{name: "show", args: "[-lang bn] [-json] <barcode>", summary: "print a product and its score breakdown", run: showProduct},
+	}},
+	{name: "prompt", args: "[-lang bn] [-score n] <barcode>", summary: "print the prompt a product's message is generated from", run: showPrompt},
+	{name: "user", summary: "manage accounts", sub: []*command{
+		{name: "create-admin", args: "-name n -email e", summary: "create an admin account, the password is read from stdin", run: createAdmin},
+		{name: "set-role", args: "-email e -role user|moderator|admin", summary: "change what an account may do", run: setRole},
+	}},
+	{name: "tokens", summary: "manage refresh tokens", sub: []*command{
+		{name: "purge-expired", summary: "delete expired refresh tokens", run: purgeExpiredTokens},
+	}},
+}}
+
+func init() {
+	var fill func(c *command, prefix string)
+	fill = func(c *command, prefix string) {
+		c.path = strings.TrimSpace(prefix + " " + c.name)
+		for _, s := range c.sub {
+			fill(s, c.path)
+		}
+	}
+	for _, c := range root.sub {
+		fill(c, "")
+	}
+}
+
+// usageError means the command was called wrongly, its usage is printed with it
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// errBadFlags is a flag parse error the flag package has already reported
+var errBadFlags = errors.New("bad flags")
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// Execute runs the command args name and returns the exit code
+func Execute(args []string) int {
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		root.printUsage()
+		return 0
+	}
+
+	c := root
+	for len(c.sub) > 0 {
+		if len(args) == 0 {
+			c.printUsage()
+			return 2
+		}
+		next := c.find(args[0])
+		if next == nil {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\n", strings.TrimSpace(c.path+" "+args[0]))
+			c.printUsage()
+			return 2
+		}
+		c, args = next, args[1:]
+	}
+
+	err := c.run(c, args)
+	var usage *usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errBadFlags):
+		return 2
+	case errors.As(err, &usage):
+		if usage.msg != "" {
+			fmt.Fprintln(os.Stderr, usage.msg)
+		}
+		c.printUsage()
+		return 2
+	default:
+		log.Printf("%s: %v", c.path, err)
+		return 1
+	}
+}
+
+func (c *command) find(name string) *command {
+	for _, s := range c.sub {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func (c *command) printUsage() {
+	w := os.Stderr
+	if c.run != nil {
+		fmt.Fprintf(w, "usage: ecoscan %s\n", strings.TrimSpace(c.path+" "+c.args))
+		return
+	}
+	if c == root {
+		fmt.Fprintln(w, "usage: ecoscan <command> [arguments]")
+	} else {
+		fmt.Fprintf(w, "usage: ecoscan %s <command> [arguments]\n", c.path)
+	}
+	fmt.Fprintln(w, "\ncommands:")
+	var list func(c *command)
+	list = func(c *command) {
+		if c.run != nil {
+			fmt.Fprintf(w, "  %-24s %s\n", c.path, c.summary)
+		}
+		for _, s := range c.sub {
+			list(s)
+		}
+	}
+	list(c)
+}
+
+// flags returns a flag set for c whose errors are left to Execute
+func (c *command) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.path, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: ecoscan %s\n", strings.TrimSpace(c.path+" "+c.args))
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args and checks the number of positional arguments left
+func (c *command) parse(fs *flag.FlagSet, args []string, nargs int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errBadFlags
+	}
+	if fs.NArg() != nargs {
+		return usagef("")
+	}
+	return nil
+}
+
+// connect opens the configured database, the caller closes it
+func connect() (*config.Config, *sqlx.DB, error) {
+	cnf := config.GetConfig()
+	db, err := sqlx.Connect("postgres", cnf.DatabaseURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("database connection error: %w", err)
+	}
+	return cnf, db, nil
+}
+
+// loadRules installs the configured scoring rulebook
+func loadRules(cnf *config.Config) error {
+	rules, err := logic.LoadRulebook(cnf.ScoringRulesPath)
+	if err != nil {
+		return fmt.Errorf("scoring rules error: %w", err)
+	}
+	logic.SetRules(rules)
+	return nil
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"ecoscan.com/migrations"
+)
+
+// migrateUp applies every pending migration
+//
+//	ecoscan migrate up
+func migrateUp(c *command, args []string) error {
+	if err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	_, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	applied, err := migrations.Up(context.Background(), db)
+	for _, m := range applied {
+		fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+	}
+	if err == nil && len(applied) == 0 {
+		fmt.Println("schema is up to date")
+	}
+	return err
+}
+
+// migrateDown reverts the newest migrations, one unless -steps says otherwise
+//
+//	ecoscan migrate down [-steps n]
+func migrateDown(c *command, args []string) error {
+	fs := c.flags()
+	steps := fs.Int("steps", 1, "number of migrations to revert")
+	if err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return usagef("-steps must be at least 1")
+	}
+	_, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	reverted, err := migrations.Down(context.Background(), db, *steps)
+	for _, m := range reverted {
+		fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+	}
+	if err == nil && len(reverted) == 0 {
+		fmt.Println("no migrations to revert")
+	}
+	return err
+}
+
+// migrateStatus lists every migration and when it was applied
+//
+//	ecoscan migrate status
+func migrateStatus(c *command, args []string) error {
+	if err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	_, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	statuses, err := migrations.Statuses(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED\t")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.Applied() {
+			applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		note := ""
+		if s.Up == "" && s.Applied() {
+			note = "not in this build"
+		} else if s.Modified {
+			note = "changed since applied"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, applied, note)
+	}
+	return w.Flush()
+}
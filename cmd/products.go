@@ -0,0 +1,352 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"ecoscan.com/barcode"
+	"ecoscan.com/i18n"
+	"ecoscan.com/jobs"
+	"ecoscan.com/logic"
+	"ecoscan.com/migrations"
+	"ecoscan.com/repo"
+	"ecoscan.com/repo/postgres"
+	"ecoscan.com/taxonomy"
+)
+
+// a small catalog of made-up products with in-store (200-prefix) barcodes, so
+// a fresh database has something to scan, search and compare
+//
+//go:embed data/demo_products.json
+var demoProducts []byte
+
+// importRow is a product read from a file and where it came from, for error messages
+type importRow struct {
+	pos     string
+	product repo.Product
+}
+
+// csvColumns are the columns an import file may have, barcode and name are required
+var csvColumns = []string{"barcode", "name", "brand_name", "category", "sub_category", "image_url",
+	"price", "packaging_material", "manufacturing_location", "disposal_method"}
+
+// seed loads the demo catalog and scores it
+//
+//	ecoscan seed
+func seed(c *command, args []string) error {
+	if err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	cnf, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := loadRules(cnf); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	pending, err := migrations.Pending(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d schema migrations are pending, run `ecoscan migrate up` first", len(pending))
+	}
+
+	rows, err := readProducts(bytes.NewReader(demoProducts), "json")
+	if err != nil {
+		return err
+	}
+	if err := loadProducts(ctx, postgres.NewProductStore(db), rows); err != nil {
+		return err
+	}
+
+	// score the catalog right away so search and alternatives work before serve runs the job
+	rescorer := jobs.NewRescorer(db, cnf.RescoreInterval)
+	n, err := rescorer.RunOnce(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("scored %d products with %s\n", n, logic.Rules().Fingerprint())
+	return nil
+}
+
+// importProducts creates or updates products from a CSV or JSON file, - reads stdin
+//
+//	ecoscan import products [-format csv|json] <file|->
+func importProducts(c *command, args []string) error {
+	fs := c.flags()
+	format := fs.String("format", "", "csv or json, taken from the file extension when empty")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	if *format != "csv" && *format != "json" {
+		return usagef("cannot tell the format of %q, pass -format csv or -format json", path)
+	}
+
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	rows, err := readProducts(in, *format)
+	if err != nil {
+		return err
+	}
+
+	_, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := loadProducts(context.Background(), postgres.NewProductStore(db), rows); err != nil {
+		return err
+	}
+	fmt.Println("new and changed products are scored by the next rescore, run `ecoscan rescore` to do it now")
+	return nil
+}
+
+// readProducts parses a JSON array of products or a CSV file with a header row
+func readProducts(r io.Reader, format string) ([]importRow, error) {
+	var rows []importRow
+	if format == "json" {
+		var products []repo.Product
+		if err := json.NewDecoder(r).Decode(&products); err != nil {
+			return nil, fmt.Errorf("reading products: %w", err)
+		}
+		for i, p := range products {
+			rows = append(rows, importRow{pos: fmt.Sprintf("item %d", i+1), product: p})
+		}
+		return rows, nil
+	}
+
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the CSV header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(csvColumns, name) {
+			return nil, fmt.Errorf("unknown column %q, the columns are %s", name, strings.Join(csvColumns, ", "))
+		}
+		index[name] = i
+	}
+	for _, required := range []string{"barcode", "name"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("the CSV has no %s column", required)
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := index[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		p := repo.Product{
+			Barcode:               field("barcode"),
+			Name:                  field("name"),
+			BrandName:             field("brand_name"),
+			Category:              field("category"),
+			SubCatergory:          field("sub_category"),
+			ImageURL:              field("image_url"),
+			PackagingMaterial:     field("packaging_material"),
+			ManufacturingLocation: field("manufacturing_location"),
+			DisposalMethod:        field("disposal_method"),
+		}
+		if v := field("price"); v != "" {
+			price, err := strconv.ParseFloat(v, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: price %q is not a number", line, v)
+			}
+			p.Price = float32(price)
+		}
+		rows = append(rows, importRow{pos: fmt.Sprintf("line %d", line), product: p})
+	}
+}
+
+// loadProducts upserts every row by its canonical barcode. bad rows are
+// reported and skipped, and make the whole load fail once the rest is in
+func loadProducts(ctx context.Context, store repo.ProductStore, rows []importRow) error {
+	created, updated, rejected := 0, 0, 0
+	for _, row := range rows {
+		p := row.product
+		code, err := barcode.Normalize(p.Barcode)
+		if err != nil {
+			log.Printf("%s: barcode %q: %v", row.pos, p.Barcode, err)
+			rejected++
+			continue
+		}
+		p.Barcode = code
+		if strings.TrimSpace(p.Name) == "" {
+			log.Printf("%s: product %s has no name", row.pos, code)
+			rejected++
+			continue
+		}
+		// unmapped values are stored as given and listed at /api/v1/taxonomy/unmapped
+		for _, u := range taxonomy.NormalizeProduct(&p) {
+			log.Printf("%s: product %s has unmapped %s %q", row.pos, code, u.Kind, u.Value)
+			if err := store.ReportUnmapped(ctx, string(u.Kind), u.Value); err != nil {
+				return fmt.Errorf("%s: reporting unmapped %s: %w", row.pos, u.Kind, err)
+			}
+		}
+
+		isNew, err := store.Upsert(ctx, p)
+		if errors.Is(err, repo.ErrInvalid) {
+			log.Printf("%s: product %s: %v", row.pos, code, err)
+			rejected++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: saving product %s: %w", row.pos, code, err)
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	fmt.Printf("%d products created, %d updated, %d rejected\n", created, updated, rejected)
+	if rejected > 0 {
+		return fmt.Errorf("%d of %d products were rejected", rejected, len(rows))
+	}
+	return nil
+}
+
+// showProduct prints a product with its stored score and how it was computed
+//
+//	ecoscan product show [-lang bn] [-json] <barcode>
+func showProduct(c *command, args []string) error {
+	fs := c.flags()
+	lang := fs.String("lang", i18n.English, "language of the rating label")
+	asJSON := fs.Bool("json", false, "print the product, score and rank as JSON")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+	if !i18n.IsSupported(*lang) {
+		return usagef("unsupported language %q, use one of %v", *lang, i18n.Supported)
+	}
+	code, err := barcode.Parse(fs.Arg(0))
+	if err != nil {
+		return usagef("invalid barcode: %v", err)
+	}
+
+	cnf, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := loadRules(cnf); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	products := postgres.NewProductStore(db)
+	p, err := repo.ProductByBarcode(ctx, products, code.GTINs())
+	if errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("no product with barcode %s", code.GTIN)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the same stored score the API serves, computed and saved when missing
+	scores := jobs.NewRescorer(db, cnf.RescoreInterval)
+	withPackaging := []repo.Product{p}
+	if err := scores.AttachPackaging(ctx, withPackaging); err != nil {
+		return fmt.Errorf("could not load packaging components: %w", err)
+	}
+	p = withPackaging[0]
+	score, err := scores.Score(ctx, p)
+	if err != nil {
+		return fmt.Errorf("could not load score: %w", err)
+	}
+	p.Score = score.Score
+	rating := i18n.T(*lang, logic.Rules().Rating(score.Score, score.Confidence, p.Category, p.SubCatergory))
+
+	var rank *repo.CategoryRank
+	if r, err := products.CategoryRank(ctx, p.ID, p.Category); err == nil {
+		rank = &r
+	} else if !errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("could not rank product: %w", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Product      repo.Product       `json:"product"`
+			Score        repo.Score         `json:"score"`
+			ScoreRating  string             `json:"score_rating"`
+			CategoryRank *repo.CategoryRank `json:"category_rank"`
+		}{p, score, rating, rank})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\n", p.Name, p.BrandName)
+	fmt.Fprintf(w, "barcode\t%s\n", p.Barcode)
+	fmt.Fprintf(w, "category\t%s / %s\n", p.Category, p.SubCatergory)
+	fmt.Fprintf(w, "price\t%.2f\n", p.Price)
+	fmt.Fprintf(w, "score\t%d, %s (confidence %.2f)\n", score.Score, rating, score.Confidence)
+	fmt.Fprintf(w, "rules\t%s, computed %s\n", score.Version, score.ComputedAt.Local().Format("2006-01-02 15:04"))
+	if rank != nil {
+		fmt.Fprintf(w, "rank\t%d of %d in %s, scores as well as or better than %.0f%% of them\n",
+			rank.Rank, rank.Peers, rank.SubCategory, rank.Percentile)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "COMPONENT\tVALUE\tCODE\tSCORE\tWEIGHT\tCERTAINTY\tREASON")
+	for _, comp := range score.Breakdown {
+		code := comp.Code
+		if comp.Unmapped {
+			code += " (unmapped)"
+		} else if comp.Defaulted {
+			code += " (default)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.2f\t%.2f\t%s\n",
+			comp.Name, comp.Value, code, comp.Score, comp.Weight, comp.Certainty, comp.Reason)
+		for _, part := range comp.Parts {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%.2f\t\t%.0fg\n",
+				part.Component, part.Material, part.Code, part.Score, part.Share, part.MassGrams)
+		}
+	}
+	return w.Flush()
+}
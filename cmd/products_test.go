@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"ecoscan.com/logic"
+	"ecoscan.com/repo"
+	"ecoscan.com/repo/memory"
+)
+
+func TestLoadProductsKeepsLocation(t *testing.T) {
+	ctx := context.Background()
+	d := memory.New()
+	rows := []importRow{
+		{pos: "line 2", product: repo.Product{
+			Barcode: "4006381333931", Name: "Spring Water",
+			PackagingMaterial: "PET bottle", ManufacturingLocation: "Dhaka, Bangladesh", DisposalMethod: "Recyclable",
+		}},
+		{pos: "line 3", product: repo.Product{
+			Barcode: "00012345678905", Name: "Tea", ManufacturingLocation: "Kolkata, India",
+		}},
+	}
+	if err := loadProducts(ctx, d.Products(), rows); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := d.Products().ByBarcode(ctx, "04006381333931")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ManufacturingLocation != "Dhaka, Bangladesh" {
+		t.Errorf("stored location %q, want it as typed", p.ManufacturingLocation)
+	}
+	var transport repo.ScoreComponent
+	for _, c := range logic.Evaluate(p).Breakdown {
+		if c.Name == "transport" {
+			transport = c
+		}
+	}
+	if transport.Code != "bd-dhaka" || transport.Score != 95 {
+		t.Errorf("transport scored %d for %q, want 95 for bd-dhaka", transport.Score, transport.Code)
+	}
+
+	unmapped, err := d.Products().UnmappedAttributes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range unmapped {
+		if u.Kind == "manufacturing_location" {
+			t.Errorf("location %q reported as unmapped", u.Value)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"ecoscan.com/barcode"
+	"ecoscan.com/i18n"
+	"ecoscan.com/jobs"
+	"ecoscan.com/prompt"
+	"ecoscan.com/repo"
+	"ecoscan.com/repo/postgres"
+)
+
+// showPrompt prints the prompt a product's message would be generated from, so
+// template changes can be reviewed without calling a provider.
+//
+//	ecoscan prompt [-lang bn] [-score n] <barcode>
+func showPrompt(c *command, args []string) error {
+	fs := c.flags()
+	lang := fs.String("lang", i18n.Default, "language of the template")
+	score := fs.Int("score", -1, "render for this score instead of the stored one")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+	if !i18n.IsSupported(*lang) {
+		return usagef("unsupported language %q, use one of %v", *lang, i18n.Supported)
+	}
+
+	code, err := barcode.Parse(fs.Arg(0))
+	if err != nil {
+		return usagef("invalid barcode: %v", err)
+	}
+
+	cnf, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := loadRules(cnf); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	p, err := repo.ProductByBarcode(ctx, postgres.NewProductStore(db), code.GTINs())
+	if err != nil {
+		return fmt.Errorf("could not load product %s: %w", code.GTIN, err)
+	}
+
+	if *score < 0 {
+		scores := jobs.NewRescorer(db, cnf.RescoreInterval)
+		products := []repo.Product{p}
+		if err := scores.AttachPackaging(ctx, products); err != nil {
+			return fmt.Errorf("could not load packaging components: %w", err)
+		}
+		s, err := scores.Score(ctx, products[0])
+		if err != nil {
+			return fmt.Errorf("could not load score: %w", err)
+		}
+		*score = s.Score
+	}
+
+	rendered, err := prompt.Render(*lang, p, *score)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("# %s, %s (%s), score %d\n\n%s\n", rendered.Version, p.Name, p.Barcode, *score, rendered.Text)
+	return nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"ecoscan.com/jobs"
+	"ecoscan.com/llm"
+	"ecoscan.com/logic"
+)
+
+// rescore recomputes scores left from older rules, or every score with -all
+//
+//	ecoscan rescore [-all]
+func rescore(c *command, args []string) error {
+	fs := c.flags()
+	all := fs.Bool("all", false, "recompute every score, not only those from older rules")
+	if err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	cnf, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := loadRules(cnf); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	rules := logic.Rules()
+	rescorer := jobs.NewRescorer(db, cnf.RescoreInterval)
+	messageCache := llm.NewMessageCache(db, cnf.MessageCacheTTL)
+	rescorer.OnScoresSaved = func(ctx context.Context, productIDs []int) {
+		if err := messageCache.Invalidate(ctx, productIDs...); err != nil {
+			log.Printf("Could not invalidate cached messages: %v", err)
+		}
+	}
+	if err := rescorer.RecordRules(ctx, rules); err != nil {
+		return fmt.Errorf("could not record scoring rules %s: %w", rules.Fingerprint(), err)
+	}
+	if *all {
+		if _, err := rescorer.MarkStale(ctx); err != nil {
+			return err
+		}
+	}
+
+	n, err := rescorer.RunOnce(ctx)
+	fmt.Printf("rescored %d products with %s\n", n, rules.Fingerprint())
+	return err
+}
@@ -1,11 +1,18 @@
 package cmd
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"strconv"
 
 	"ecoscan.com/config"
+	"ecoscan.com/jobs"
+	"ecoscan.com/llm"
+	"ecoscan.com/logic"
+	"ecoscan.com/mail"
+	"ecoscan.com/migrations"
+	"ecoscan.com/repo/postgres"
 	"ecoscan.com/rest/handlers/product"
 	"ecoscan.com/rest/handlers/user"
 	"ecoscan.com/rest/middlewares"
@@ -13,11 +20,22 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// serve runs the HTTP API
+//
+//	ecoscan serve
+func serve(c *command, args []string) error {
+	if err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	Serve()
+	return nil
+}
+
+// Serve runs the HTTP API until it fails
 func Serve() {
-	
+
 	cnf := config.GetConfig()
 
-	
 	db, err := sqlx.Connect("postgres", cnf.DatabaseURL)
 	if err != nil {
 		log.Fatalf("Database connection error: %v", err)
@@ -26,15 +44,73 @@ func Serve() {
 
 	log.Println("Database Connected")
 
-	
+	// instances starting together wait on the migration lock, the first applies
+	if cnf.MigrateOnStart {
+		applied, err := migrations.Up(context.Background(), db)
+		if err != nil {
+			log.Fatalf("Migration error: %v", err)
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+	} else if pending, err := migrations.Pending(context.Background(), db); err != nil {
+		log.Printf("Could not check schema migrations: %v", err)
+	} else if len(pending) > 0 {
+		log.Printf("%d schema migrations are pending, run `ecoscan migrate up`", len(pending))
+	}
+
+	// a broken rulebook at startup is fatal, a broken reload only logs
+	rules, err := logic.LoadRulebook(cnf.ScoringRulesPath)
+	if err != nil {
+		log.Fatalf("Scoring rules error: %v", err)
+	}
+	logic.SetRules(rules)
+	log.Printf("Scoring rules %s loaded", rules.Fingerprint())
+
 	mngr := middlewares.NewManager()
 	mngr.Use(
 		middlewares.Logger,
 		middlewares.CORS,
 	)
 
-	productHandler := product.NewProductHandler(db)
-	userHandler := user.NewUserHandler(db)
+	messages, err := llm.FromConfig(cnf, db)
+	if err != nil {
+		log.Fatalf("Message provider error: %v", err)
+	}
+	log.Printf("Motivational messages from %s", messages.Name())
+	messageCache := llm.NewMessageCache(db, cnf.MessageCacheTTL)
+
+	// keeps stored scores on the current scoring version
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rescorer := jobs.NewRescorer(db, cnf.RescoreInterval)
+	// a rewritten score may land in another bucket, drop the old messages either way
+	rescorer.OnScoresSaved = func(ctx context.Context, productIDs []int) {
+		if err := messageCache.Invalidate(ctx, productIDs...); err != nil {
+			log.Printf("Could not invalidate cached messages: %v", err)
+		}
+	}
+	rescorer.Start(ctx)
+	logic.WatchRules(ctx, cnf.ScoringRulesPath, cnf.ScoringRulesReload, func(*logic.Rulebook) {
+		rescorer.Trigger()
+	})
+
+	productHandler := product.NewProductHandler(
+		postgres.NewProductStore(db),
+		postgres.NewRequestStore(db),
+		rescorer,
+		messages,
+		messageCache,
+	)
+	mailer, err := mail.FromConfig(cnf)
+	if err != nil {
+		log.Fatalf("Mail provider error: %v", err)
+	}
+	log.Printf("Mail goes out via %s, links point to %s", mailer.Name(), cnf.AppBaseURL)
+
+	tokens := postgres.NewTokenStore(db)
+	middlewares.SetSessions(tokens)
+	userHandler := user.NewUserHandler(postgres.NewUserStore(db), tokens, mailer)
 
 	mux := http.NewServeMux()
 	productHandler.RegisterRoutes(mux, mngr)
@@ -43,5 +119,5 @@ func Serve() {
 	addr := ":" + strconv.Itoa(cnf.HttpPort)
 
 	log.Printf("Server running on %s\n", addr)
-	http.ListenAndServe(addr, mngr.Chain(mux))
-}
\ No newline at end of file
+	log.Fatal(http.ListenAndServe(addr, mngr.Chain(mux)))
+}
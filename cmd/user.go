@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"ecoscan.com/repo"
+	"ecoscan.com/repo/postgres"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// createAdmin creates an admin account, the first one has to come from here.
+// the password is the first line of stdin, so it stays out of the shell
+// history and the process list.
+//
+//	echo "$PASSWORD" | ecoscan user create-admin -name n -email e
+func createAdmin(c *command, args []string) error {
+	fs := c.flags()
+	name := fs.String("name", "", "display name")
+	email := fs.String("email", "", "login email")
+	if err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *name == "" || *email == "" {
+		return usagef("-name and -email are required")
+	}
+
+	fmt.Fprint(os.Stderr, "password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return fmt.Errorf("reading the password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if len(password) < 8 {
+		return errors.New("the password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	u, err := postgres.NewUserStore(db).Create(context.Background(), repo.User{
+		Name:         *name,
+		Email:        *email,
+		PasswordHash: string(hash),
+		Role:         repo.RoleAdmin,
+	})
+	if errors.Is(err, repo.ErrConflict) {
+		return fmt.Errorf("%s already has an account, use `ecoscan user set-role` to make it an admin", *email)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created admin %d <%s>\n", u.ID, u.Email)
+	return nil
+}
+
+// setRole changes what an existing account may do, it takes effect on the
+// account's next request since every request reads the role from its session
+//
+//	ecoscan user set-role -email e -role user|moderator|admin
+func setRole(c *command, args []string) error {
+	fs := c.flags()
+	email := fs.String("email", "", "login email")
+	role := fs.String("role", "", "user, moderator or admin")
+	if err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *email == "" {
+		return usagef("-email is required")
+	}
+	if !repo.Role(*role).Valid() {
+		return usagef("-role must be user, moderator or admin")
+	}
+
+	_, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	users := postgres.NewUserStore(db)
+	u, err := users.ByEmail(ctx, *email)
+	if errors.Is(err, repo.ErrNotFound) {
+		return fmt.Errorf("no account with email %s", *email)
+	}
+	if err != nil {
+		return err
+	}
+	if err := users.SetRole(ctx, u.ID, repo.Role(*role)); err != nil {
+		return err
+	}
+	fmt.Printf("user %d <%s> is now %s, was %s\n", u.ID, u.Email, *role, u.Role)
+	return nil
+}
+
+// purgeExpiredTokens deletes refresh tokens past their expiry
+//
+//	ecoscan tokens purge-expired
+func purgeExpiredTokens(c *command, args []string) error {
+	if err := c.parse(c.flags(), args, 0); err != nil {
+		return err
+	}
+	_, db, err := connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	n, err := postgres.NewTokenStore(db).PurgeExpired(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("deleted %d expired refresh tokens\n", n)
+	return nil
+}
@@ -4,17 +4,69 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Version       string
-	ServiceName   string
-	HttpPort      int
-	JWTSecretKey  string
-	CloudinaryURL string
-	DatabaseURL   string 
+	Version         string
+	ServiceName     string
+	HttpPort        int
+	JWTSecretKey    string
+	CloudinaryURL   string
+	DatabaseURL     string
+	RescoreInterval time.Duration
+	// optional JSON rulebook, the built-in rules are used when empty
+	ScoringRulesPath   string
+	ScoringRulesReload time.Duration
+	// openrouter, gemini, openai (any compatible API, e.g. Ollama) or template
+	MessageProvider string
+	OpenRouter      ProviderConfig
+	Gemini          ProviderConfig
+	OpenAI          ProviderConfig
+	MessageCacheTTL time.Duration
+	// extra attempts after a rate limit, server or network error
+	MessageRetries int
+	// consecutive failures that open a provider's circuit, and for how long
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// apply pending schema migrations before serving
+	MigrateOnStart bool
+	// smtp, or log to print mail, or write it to MailDir when that is set
+	MailProvider string
+	MailFrom     string
+	MailDir      string
+	SMTP         SMTPConfig
+	// the web app links in mail point to, e.g. https://ecoscan.com
+	AppBaseURL string
+	// how long verification and password reset links work
+	EmailVerifyTTL   time.Duration
+	PasswordResetTTL time.Duration
+}
+
+// SMTPConfig is read from SMTP_HOST, SMTP_PORT, SMTP_USERNAME and SMTP_PASSWORD,
+// mail is sent with STARTTLS and without auth when no username is set
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// ProviderConfig configures one LLM provider, read from <PREFIX>_API_KEY,
+// <PREFIX>_BASE_URL, <PREFIX>_MODEL, <PREFIX>_TIMEOUT, <PREFIX>_DAILY_REQUESTS
+// and <PREFIX>_DAILY_TOKENS
+type ProviderConfig struct {
+	APIKey  string
+	BaseURL string
+	Model   string
+	Timeout time.Duration
+	// zero means unlimited
+	DailyRequests int
+	DailyTokens   int
 }
 
 var configurations *Config
@@ -25,10 +77,9 @@ func loadConfig() {
 		fmt.Println("No .env file found, using environment variables")
 	}
 
-
 	httpPortStr := os.Getenv("PORT")
 	if httpPortStr == "" {
-		httpPortStr = os.Getenv("HTTP_PORT") 
+		httpPortStr = os.Getenv("HTTP_PORT")
 		if httpPortStr == "" {
 			fmt.Println("PORT or HTTP_PORT is required")
 			os.Exit(1)
@@ -40,16 +91,115 @@ func loadConfig() {
 		os.Exit(1)
 	}
 
-	
-	configurations = &Config{
-		Version:       os.Getenv("VERSION"),
-		ServiceName:   os.Getenv("SERVICE_NAME"),
-		HttpPort:      int(port),
-		JWTSecretKey:  os.Getenv("JWT_SECRET_KEY"),
-		CloudinaryURL: os.Getenv("CLOUDINARY_URL"),
-		DatabaseURL:   os.Getenv("DATABASE_URL"), 
+	// how often stale product scores are recomputed, e.g. "30m"
+	rescoreInterval := time.Hour
+	if v := os.Getenv("RESCORE_INTERVAL"); v != "" {
+		rescoreInterval, err = time.ParseDuration(v)
+		if err != nil || rescoreInterval <= 0 {
+			fmt.Println("RESCORE_INTERVAL must be a positive duration")
+			os.Exit(1)
+		}
+	}
+
+	rulesReload := 30 * time.Second
+	if v := os.Getenv("SCORING_RULES_RELOAD"); v != "" {
+		rulesReload, err = time.ParseDuration(v)
+		if err != nil || rulesReload <= 0 {
+			fmt.Println("SCORING_RULES_RELOAD must be a positive duration")
+			os.Exit(1)
+		}
+	}
+
+	openRouter := loadProvider("OPENROUTER", "", "meta-llama/llama-4-maverick:free", 12*time.Second)
+	gemini := loadProvider("GEMINI", "", "gemini-1.5-flash", 12*time.Second)
+	openAI := loadProvider("OPENAI", "http://localhost:11434/v1", "llama3.1", 30*time.Second)
+
+	// without an explicit choice keep the old behaviour: OpenRouter when a key is set, canned messages otherwise
+	messageProvider := os.Getenv("MESSAGE_PROVIDER")
+	if messageProvider == "" {
+		messageProvider = "template"
+		if openRouter.APIKey != "" {
+			messageProvider = "openrouter"
+		}
+	}
+
+	messageCacheTTL := 7 * 24 * time.Hour
+	if v := os.Getenv("MESSAGE_CACHE_TTL"); v != "" {
+		messageCacheTTL, err = time.ParseDuration(v)
+		if err != nil || messageCacheTTL <= 0 {
+			fmt.Println("MESSAGE_CACHE_TTL must be a positive duration")
+			os.Exit(1)
+		}
+	}
+
+	messageRetries := loadInt("MESSAGE_RETRIES", 2)
+	breakerThreshold := loadInt("MESSAGE_BREAKER_THRESHOLD", 5)
+	if breakerThreshold < 1 {
+		fmt.Println("MESSAGE_BREAKER_THRESHOLD must be at least 1")
+		os.Exit(1)
+	}
+
+	breakerCooldown := 30 * time.Second
+	if v := os.Getenv("MESSAGE_BREAKER_COOLDOWN"); v != "" {
+		breakerCooldown, err = time.ParseDuration(v)
+		if err != nil || breakerCooldown <= 0 {
+			fmt.Println("MESSAGE_BREAKER_COOLDOWN must be a positive duration")
+			os.Exit(1)
+		}
+	}
+
+	migrateOnStart := false
+	if v := os.Getenv("MIGRATE_ON_START"); v != "" {
+		migrateOnStart, err = strconv.ParseBool(v)
+		if err != nil {
+			fmt.Println("MIGRATE_ON_START must be true or false")
+			os.Exit(1)
+		}
+	}
+
+	mailProvider := os.Getenv("MAIL_PROVIDER")
+	if mailProvider == "" {
+		mailProvider = "log"
+	}
+	smtpConfig := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     loadInt("SMTP_PORT", 587),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		Timeout:  loadDuration("SMTP_TIMEOUT", 15*time.Second),
+	}
+	appBaseURL := strings.TrimRight(os.Getenv("APP_BASE_URL"), "/")
+	if appBaseURL == "" {
+		appBaseURL = "http://localhost:" + httpPortStr
 	}
 
+	configurations = &Config{
+		Version:            os.Getenv("VERSION"),
+		ServiceName:        os.Getenv("SERVICE_NAME"),
+		HttpPort:           int(port),
+		JWTSecretKey:       os.Getenv("JWT_SECRET_KEY"),
+		CloudinaryURL:      os.Getenv("CLOUDINARY_URL"),
+		DatabaseURL:        os.Getenv("DATABASE_URL"),
+		RescoreInterval:    rescoreInterval,
+		ScoringRulesPath:   os.Getenv("SCORING_RULES_PATH"),
+		ScoringRulesReload: rulesReload,
+		MessageProvider:    messageProvider,
+		OpenRouter:         openRouter,
+		Gemini:             gemini,
+		OpenAI:             openAI,
+		MessageCacheTTL:    messageCacheTTL,
+		MessageRetries:     messageRetries,
+		BreakerThreshold:   breakerThreshold,
+		BreakerCooldown:    breakerCooldown,
+		MigrateOnStart:     migrateOnStart,
+		MailProvider:       mailProvider,
+		MailFrom:           os.Getenv("MAIL_FROM"),
+		MailDir:            os.Getenv("MAIL_DIR"),
+		SMTP:               smtpConfig,
+		AppBaseURL:         appBaseURL,
+		EmailVerifyTTL:     loadDuration("EMAIL_VERIFY_TTL", 48*time.Hour),
+		PasswordResetTTL:   loadDuration("PASSWORD_RESET_TTL", time.Hour),
+	}
 
 	if configurations.DatabaseURL == "" {
 		fmt.Println("DATABASE_URL is required")
@@ -59,6 +209,64 @@ func loadConfig() {
 		fmt.Println("JWT_SECRET_KEY is required")
 		os.Exit(1)
 	}
+	if mailProvider == "smtp" && (smtpConfig.Host == "" || configurations.MailFrom == "") {
+		fmt.Println("SMTP_HOST and MAIL_FROM are required with MAIL_PROVIDER=smtp")
+		os.Exit(1)
+	}
+}
+
+func loadProvider(prefix, baseURL, model string, timeout time.Duration) ProviderConfig {
+	cnf := ProviderConfig{
+		APIKey:  os.Getenv(prefix + "_API_KEY"),
+		BaseURL: os.Getenv(prefix + "_BASE_URL"),
+		Model:   os.Getenv(prefix + "_MODEL"),
+		Timeout: timeout,
+	}
+	if cnf.BaseURL == "" {
+		cnf.BaseURL = baseURL
+	}
+	if cnf.Model == "" {
+		cnf.Model = model
+	}
+	if v := os.Getenv(prefix + "_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			fmt.Printf("%s_TIMEOUT must be a positive duration\n", prefix)
+			os.Exit(1)
+		}
+		cnf.Timeout = d
+	}
+	cnf.DailyRequests = loadInt(prefix+"_DAILY_REQUESTS", 0)
+	cnf.DailyTokens = loadInt(prefix+"_DAILY_TOKENS", 0)
+	return cnf
+}
+
+// loadInt reads a non-negative integer, fallback when unset
+func loadInt(name string, fallback int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		fmt.Printf("%s must be a non-negative number\n", name)
+		os.Exit(1)
+	}
+	return n
+}
+
+// loadDuration reads a positive duration like "90m", fallback when unset
+func loadDuration(name string, fallback time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		fmt.Printf("%s must be a positive duration\n", name)
+		os.Exit(1)
+	}
+	return d
 }
 
 func GetConfig() *Config {
@@ -66,4 +274,4 @@ func GetConfig() *Config {
 		loadConfig()
 	}
 	return configurations
-}
\ No newline at end of file
+}
@@ -0,0 +1,160 @@
+package geo
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+/* offline gazetteer of country, bangladesh division and major city centroids.
+it only needs to be good enough to tell a Dhaka factory from a Shanghai one,
+precision_km says how far off a centroid can be for that place */
+
+type Kind string
+
+const (
+	Country  Kind = "country"
+	Division Kind = "division"
+	City     Kind = "city"
+)
+
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+type Place struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Kind        Kind     `json:"kind"`
+	Country     string   `json:"country"`
+	Lat         float64  `json:"lat"`
+	Lng         float64  `json:"lng"`
+	PrecisionKm float64  `json:"precision_km"`
+	Aliases     []string `json:"aliases,omitempty"`
+}
+
+func (p Place) Point() Point {
+	return Point{Lat: p.Lat, Lng: p.Lng}
+}
+
+// Label is the display form, e.g. "Sylhet, Bangladesh"
+func (p Place) Label() string {
+	if p.Kind == Country {
+		return p.Name
+	}
+	if c, ok := byID[p.Country]; ok {
+		return p.Name + ", " + c.Name
+	}
+	return p.Name
+}
+
+//go:embed gazetteer.json
+var gazetteerJSON []byte
+
+var (
+	places []Place
+	byID   = map[string]Place{}
+	byName = map[string][]Place{}
+)
+
+func init() {
+	if err := json.Unmarshal(gazetteerJSON, &places); err != nil {
+		panic("geo: decoding gazetteer: " + err.Error())
+	}
+	for _, p := range places {
+		if _, dup := byID[p.ID]; dup {
+			panic("geo: duplicate place id " + p.ID)
+		}
+		byID[p.ID] = p
+		for _, name := range append([]string{p.Name}, p.Aliases...) {
+			key := normalizeKey(name)
+			byName[key] = append(byName[key], p)
+		}
+	}
+}
+
+// Lookup finds a place by its gazetteer id
+func Lookup(id string) (Place, bool) {
+	p, ok := byID[id]
+	return p, ok
+}
+
+// Resolve geocodes free text like "Dhaka, Bangladesh" or "Made in Sylhet".
+// every comma separated part is tried, a matched country narrows the other
+// parts to that country and the most precise match wins.
+func Resolve(text string) (Place, bool) {
+	parts := strings.Split(text, ",")
+	if len(parts) > 1 {
+		parts = append(parts, text)
+	}
+
+	var candidates []Place
+	country := ""
+	for _, part := range parts {
+		for _, p := range byName[normalizeKey(part)] {
+			candidates = append(candidates, p)
+			if p.Kind == Country {
+				country = p.Country
+			}
+		}
+	}
+
+	var best Place
+	found := false
+	for _, p := range candidates {
+		if country != "" && p.Country != country {
+			continue
+		}
+		if !found || p.PrecisionKm < best.PrecisionKm {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// Distance is the great-circle distance in km
+func Distance(a, b Point) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(d float64) float64 { return d * math.Pi / 180 }
+
+	dLat := toRad(b.Lat - a.Lat)
+	dLng := toRad(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// ParsePoint validates a user supplied coordinate pair
+func ParsePoint(lat, lng float64) (Point, error) {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return Point{}, fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if math.IsNaN(lng) || lng < -180 || lng > 180 {
+		return Point{}, fmt.Errorf("longitude must be between -180 and 180")
+	}
+	return Point{Lat: lat, Lng: lng}, nil
+}
+
+var madeInPrefixes = []string{"made in ", "manufactured in ", "product of ", "produced in "}
+
+func normalizeKey(s string) string {
+	s = strings.ToLower(s)
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\'' || r == '’':
+			return -1
+		case unicode.IsPunct(r):
+			return ' '
+		}
+		return r
+	}, s)
+	s = strings.Join(strings.Fields(s), " ")
+	for _, prefix := range madeInPrefixes {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	return s
+}
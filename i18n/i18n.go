@@ -0,0 +1,123 @@
+package i18n
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* user facing strings in every language the app speaks. the catalog is keyed
+by message id, rating labels are keyed by the rulebook's english label so a
+custom label without a translation is shown as written */
+
+const (
+	Bangla  = "bn"
+	English = "en"
+	// Default is served when the client asks for nothing we support
+	Default = Bangla
+)
+
+var Supported = []string{Bangla, English}
+
+//go:embed catalog.json
+var catalogJSON []byte
+
+var catalog map[string]map[string]string
+
+func init() {
+	if err := json.Unmarshal(catalogJSON, &catalog); err != nil {
+		panic("i18n: decoding catalog: " + err.Error())
+	}
+	for _, lang := range Supported {
+		if _, ok := catalog[lang]; !ok {
+			panic("i18n: catalog has no " + lang + " strings")
+		}
+	}
+	for key := range catalog[English] {
+		for _, lang := range Supported {
+			if _, ok := catalog[lang][key]; !ok {
+				panic(fmt.Sprintf("i18n: %q has no %s translation", key, lang))
+			}
+		}
+	}
+}
+
+// IsSupported reports whether lang is one of Supported
+func IsSupported(lang string) bool {
+	for _, l := range Supported {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// T looks up key in lang, formatting it with args. unknown keys come back as
+// written, which is what rulebook labels without a translation need.
+func T(lang, key string, args ...any) string {
+	s, ok := catalog[lang][key]
+	if !ok {
+		s, ok = catalog[English][key]
+	}
+	if !ok {
+		s = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(s, args...)
+	}
+	return s
+}
+
+// Negotiate picks the response language: a supported ?lang= wins, then the
+// best Accept-Language match, then Default
+func Negotiate(r *http.Request) string {
+	if lang := base(r.URL.Query().Get("lang")); IsSupported(lang) {
+		return lang
+	}
+	if lang, ok := fromAcceptLanguage(r.Header.Get("Accept-Language")); ok {
+		return lang
+	}
+	return Default
+}
+
+// fromAcceptLanguage parses "en-US,en;q=0.9,bn;q=0.8" style headers
+func fromAcceptLanguage(header string) (string, bool) {
+	type option struct {
+		lang string
+		q    float64
+	}
+	var options []option
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if lang := base(tag); IsSupported(lang) && q > 0 {
+			options = append(options, option{lang, q})
+		}
+	}
+	if len(options) == 0 {
+		return "", false
+	}
+	// stable keeps header order between equal weights
+	sort.SliceStable(options, func(i, j int) bool { return options[i].q > options[j].q })
+	return options[0].lang, true
+}
+
+// base reduces a tag like "en-US" or "bn_BD" to its primary language
+func base(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,320 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"ecoscan.com/logic"
+	"ecoscan.com/repo"
+	"ecoscan.com/taxonomy"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const productColumns = `p.id, p.barcode, p.name, p.brand_name, p.category, p.sub_category,
+	p.image_url, p.price, p.packaging_material, p.manufacturing_location, p.disposal_method`
+
+const upsertScoreQuery = `
+	INSERT INTO product_scores (
+		product_id, score, packaging_score, location_score, disposal_score, breakdown, confidence, scoring_version, computed_at
+	)
+	VALUES (:product_id, :score, :packaging_score, :location_score, :disposal_score, :breakdown, :confidence, :scoring_version, :computed_at)
+	ON CONFLICT (product_id) DO UPDATE SET
+		score = EXCLUDED.score,
+		packaging_score = EXCLUDED.packaging_score,
+		location_score = EXCLUDED.location_score,
+		disposal_score = EXCLUDED.disposal_score,
+		breakdown = EXCLUDED.breakdown,
+		confidence = EXCLUDED.confidence,
+		scoring_version = EXCLUDED.scoring_version,
+		computed_at = EXCLUDED.computed_at
+`
+
+// Rescorer keeps product_scores in step with the current scoring rules
+type Rescorer struct {
+	DB        *sqlx.DB
+	Interval  time.Duration
+	BatchSize int
+	// OnScoresSaved runs after scores were written, e.g. to drop cached messages
+	OnScoresSaved func(ctx context.Context, productIDs []int)
+
+	trigger chan struct{}
+}
+
+func NewRescorer(db *sqlx.DB, interval time.Duration) *Rescorer {
+	return &Rescorer{
+		DB:        db,
+		Interval:  interval,
+		BatchSize: 200,
+		trigger:   make(chan struct{}, 1),
+	}
+}
+
+// Start rescores stale rows right away and then on every tick or Trigger until ctx is done
+func (r *Rescorer) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+
+		for {
+			rules := logic.Rules()
+			if err := r.RecordRules(ctx, rules); err != nil {
+				log.Printf("Could not record scoring rules %s: %v", rules.Fingerprint(), err)
+			}
+
+			n, err := r.RunOnce(ctx)
+			if err != nil {
+				log.Printf("Rescore job failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Rescore job updated %d product scores to %s", n, rules.Fingerprint())
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-r.trigger:
+			}
+		}
+	}()
+}
+
+// Trigger asks the running job for an immediate pass, e.g. after the rules were reloaded
+func (r *Rescorer) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+		// a pass is already queued
+	}
+}
+
+// RecordRules archives a rulebook so every stored scoring_version can be traced back to its values
+func (r *Rescorer) RecordRules(ctx context.Context, rb *logic.Rulebook) error {
+	body, err := json.Marshal(rb)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.ExecContext(ctx, `
+		INSERT INTO scoring_rules (fingerprint, version, body)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (fingerprint) DO NOTHING
+	`, rb.Fingerprint(), rb.Version, body)
+	return err
+}
+
+// MarkStale marks every stored score stale, so the next pass recomputes all of them
+func (r *Rescorer) MarkStale(ctx context.Context) (int64, error) {
+	res, err := r.DB.ExecContext(ctx, `UPDATE product_scores SET scoring_version = ''`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RunOnce rescores every product without a score for the current version
+func (r *Rescorer) RunOnce(ctx context.Context) (int, error) {
+	query := `
+		SELECT ` + productColumns + `
+		FROM products p
+		LEFT JOIN product_scores s ON s.product_id = p.id
+		WHERE s.product_id IS NULL OR s.scoring_version <> $1
+		ORDER BY p.id
+		LIMIT $2
+	`
+
+	// pin the rulebook for the whole pass, a reload mid-way would otherwise never finish
+	rules := logic.Rules()
+	total := 0
+	for {
+		var stale []repo.Product
+		if err := r.DB.SelectContext(ctx, &stale, query, rules.Fingerprint(), r.BatchSize); err != nil {
+			return total, fmt.Errorf("selecting stale products: %w", err)
+		}
+		if len(stale) == 0 {
+			return total, nil
+		}
+		if err := r.AttachPackaging(ctx, stale); err != nil {
+			return total, err
+		}
+
+		scores := make([]repo.Score, 0, len(stale))
+		for _, p := range stale {
+			scores = append(scores, logic.EvaluateWith(rules, p))
+		}
+		if err := r.save(ctx, scores); err != nil {
+			return total, err
+		}
+		r.reportUnmapped(ctx, scores)
+		total += len(stale)
+
+		if len(stale) < r.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// Scores returns the stored score for each product, computing and saving the
+// ones that were never scored. stale rows are served as is, the job refreshes them.
+func (r *Rescorer) Scores(ctx context.Context, products []repo.Product) (map[int]repo.Score, error) {
+	result := make(map[int]repo.Score, len(products))
+	if len(products) == 0 {
+		return result, nil
+	}
+
+	ids := make([]int64, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, int64(p.ID))
+	}
+
+	var stored []repo.Score
+	query := `
+		SELECT product_id, score, packaging_score, location_score, disposal_score, breakdown, confidence, scoring_version, computed_at
+		FROM product_scores WHERE product_id = ANY($1)
+	`
+	if err := r.DB.SelectContext(ctx, &stored, query, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("loading stored scores: %w", err)
+	}
+	for _, s := range stored {
+		result[s.ProductID] = s
+	}
+
+	var unscored []repo.Product
+	for _, p := range products {
+		if _, ok := result[p.ID]; !ok {
+			unscored = append(unscored, p)
+		}
+	}
+	if err := r.AttachPackaging(ctx, unscored); err != nil {
+		return nil, err
+	}
+
+	var missing []repo.Score
+	for _, p := range unscored {
+		s := logic.Evaluate(p)
+		result[p.ID] = s
+		missing = append(missing, s)
+	}
+	if len(missing) > 0 {
+		if err := r.save(ctx, missing); err != nil {
+			// the computed values are still correct, only persisting failed
+			log.Printf("Could not persist %d new product scores: %v", len(missing), err)
+		}
+		r.reportUnmapped(ctx, missing)
+	}
+
+	return result, nil
+}
+
+// AttachPackaging loads the packaging components of every product in place
+func (r *Rescorer) AttachPackaging(ctx context.Context, products []repo.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, int64(p.ID))
+	}
+
+	var components []repo.PackagingComponent
+	query := `
+		SELECT id, product_id, component, material, mass_grams, recyclable
+		FROM product_packaging_components
+		WHERE product_id = ANY($1)
+		ORDER BY product_id, mass_grams DESC, id
+	`
+	if err := r.DB.SelectContext(ctx, &components, query, pq.Array(ids)); err != nil {
+		return fmt.Errorf("loading packaging components: %w", err)
+	}
+
+	byProduct := make(map[int][]repo.PackagingComponent)
+	for _, c := range components {
+		byProduct[c.ProductID] = append(byProduct[c.ProductID], c)
+	}
+	for i := range products {
+		products[i].PackagingComponents = byProduct[products[i].ID]
+	}
+	return nil
+}
+
+// Score is Scores for a single product
+func (r *Rescorer) Score(ctx context.Context, product repo.Product) (repo.Score, error) {
+	scores, err := r.Scores(ctx, []repo.Product{product})
+	if err != nil {
+		return repo.Score{}, err
+	}
+	return scores[product.ID], nil
+}
+
+func (r *Rescorer) save(ctx context.Context, scores []repo.Score) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting score transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	ids := make([]int, 0, len(scores))
+	for _, s := range scores {
+		if _, err := tx.NamedExecContext(ctx, upsertScoreQuery, s); err != nil {
+			return fmt.Errorf("saving score for product %d: %w", s.ProductID, err)
+		}
+		ids = append(ids, s.ProductID)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if r.OnScoresSaved != nil {
+		r.OnScoresSaved(ctx, ids)
+	}
+	return nil
+}
+
+// breakdown component name -> the taxonomy it was mapped with
+var componentKinds = map[string]taxonomy.Kind{
+	"packaging": taxonomy.PackagingMaterial,
+	"transport": taxonomy.ManufacturingLocation,
+	"disposal":  taxonomy.DisposalMethod,
+}
+
+// reportUnmapped records attribute values neither the taxonomy nor the gazetteer
+// could map so the data team can add a synonym or fix the product instead of living with the default
+func (r *Rescorer) reportUnmapped(ctx context.Context, scores []repo.Score) {
+	for _, s := range scores {
+		for _, c := range s.Breakdown {
+			if !c.Unmapped {
+				continue
+			}
+			kind := componentKinds[c.Name]
+
+			// component based packaging reports the offending part, not the summary
+			values := []string{c.Value}
+			if len(c.Parts) > 0 {
+				values = values[:0]
+				for _, part := range c.Parts {
+					if part.Unmapped {
+						values = append(values, part.Material)
+					}
+				}
+			}
+
+			for _, value := range values {
+				_, err := r.DB.ExecContext(ctx, `
+					INSERT INTO unmapped_attribute_values (kind, value)
+					VALUES ($1, $2)
+					ON CONFLICT (kind, value) DO UPDATE SET last_seen = NOW()
+				`, kind, value)
+				if err != nil {
+					log.Printf("Could not report unmapped %s %q: %v", kind, value, err)
+					return
+				}
+				log.Printf("Product %d has unmapped %s %q", s.ProductID, kind, value)
+			}
+		}
+	}
+}
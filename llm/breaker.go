@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen means the provider failed too often and isn't called for now
+var ErrCircuitOpen = errors.New("provider circuit is open")
+
+type BreakerState string
+
+const (
+	Closed   BreakerState = "closed"
+	Open     BreakerState = "open"
+	HalfOpen BreakerState = "half_open"
+)
+
+// Breaker stops calling a provider after Threshold consecutive failures.
+// after Cooldown a single probe call is let through: success closes the
+// circuit again, failure opens it for another Cooldown.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		state:     Closed,
+	}
+}
+
+// Allow reports ErrCircuitOpen when a call must not be made
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.Cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = HalfOpen
+		b.probing = true
+		return nil
+	case HalfOpen:
+		// one probe at a time
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Record feeds the outcome of an allowed call back into the breaker
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil {
+		b.state = Closed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.Threshold {
+		b.state = Open
+		b.openedAt = time.Now()
+	}
+}
+
+// Release ends an allowed call that says nothing about the provider, e.g.
+// one the caller gave up on, so a half-open circuit can probe again
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// State is the current state, consecutive failures and when an open circuit
+// lets the next probe through
+func (b *Breaker) State() (state BreakerState, failures int, probeAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open {
+		probeAt = b.openedAt.Add(b.Cooldown)
+	}
+	return b.state, b.failures, probeAt
+}
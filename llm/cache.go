@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CacheKey identifies a generated message. a new score bucket, language or
+// prompt version simply misses instead of serving an outdated message.
+type CacheKey struct {
+	ProductID     int
+	ScoreBucket   int
+	Lang          string
+	PromptVersion string
+}
+
+func (k CacheKey) String() string {
+	return fmt.Sprintf("%d/%d/%s/%s", k.ProductID, k.ScoreBucket, k.Lang, k.PromptVersion)
+}
+
+// ScoreBucket groups scores by tens so a one point rescore keeps its message
+func ScoreBucket(score int) int {
+	return score / 10
+}
+
+// MessageCache stores generated messages in product_message_cache
+type MessageCache struct {
+	DB  *sqlx.DB
+	TTL time.Duration
+
+	inflight sync.Map
+}
+
+func NewMessageCache(db *sqlx.DB, ttl time.Duration) *MessageCache {
+	return &MessageCache{
+		DB:  db,
+		TTL: ttl,
+	}
+}
+
+// Get returns the cached message for key unless it has expired
+func (c *MessageCache) Get(ctx context.Context, key CacheKey) (string, bool) {
+	var message string
+	err := c.DB.GetContext(ctx, &message, `
+		SELECT message FROM product_message_cache
+		WHERE product_id = $1 AND score_bucket = $2 AND lang = $3 AND prompt_version = $4
+			AND expires_at > NOW()
+	`, key.ProductID, key.ScoreBucket, key.Lang, key.PromptVersion)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Message cache read failed for %s: %v", key, err)
+		}
+		return "", false
+	}
+	return message, true
+}
+
+func (c *MessageCache) Put(ctx context.Context, key CacheKey, provider, message string) error {
+	_, err := c.DB.ExecContext(ctx, `
+		INSERT INTO product_message_cache (product_id, score_bucket, lang, prompt_version, provider, message, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (product_id, score_bucket, lang, prompt_version) DO UPDATE SET
+			provider = EXCLUDED.provider,
+			message = EXCLUDED.message,
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+	`, key.ProductID, key.ScoreBucket, key.Lang, key.PromptVersion, provider, message, time.Now().Add(c.TTL))
+	return err
+}
+
+// Invalidate drops every cached message of the given products, it is the hook
+// the rescorer calls whenever a product's score is rewritten
+func (c *MessageCache) Invalidate(ctx context.Context, productIDs ...int) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(productIDs))
+	for _, id := range productIDs {
+		ids = append(ids, int64(id))
+	}
+	_, err := c.DB.ExecContext(ctx, `DELETE FROM product_message_cache WHERE product_id = ANY($1)`, pq.Array(ids))
+	return err
+}
+
+// Pending is a message generation that may still be running
+type Pending struct {
+	done    chan struct{}
+	message string
+	err     error
+}
+
+// Wait blocks until the message is generated or ctx ends. giving up on a
+// pending message doesn't stop it, it is still cached for the next request.
+func (p *Pending) Wait(ctx context.Context) (string, error) {
+	select {
+	case <-p.done:
+		return p.message, p.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Fill generates and stores the message for key in the background. concurrent
+// requests for the same cold product share a single generation, only the first
+// caller's generate func runs.
+func (c *MessageCache) Fill(key CacheKey, provider string, timeout time.Duration, generate func(ctx context.Context) (string, error)) *Pending {
+	return fill(&c.inflight, key, timeout, generate, func(ctx context.Context, message string) error {
+		return c.Put(ctx, key, provider, message)
+	})
+}
+
+// fill runs generate once per key at a time and hands a good message to put
+func fill(inflight *sync.Map, key CacheKey, timeout time.Duration, generate func(ctx context.Context) (string, error), put func(ctx context.Context, message string) error) *Pending {
+	p := &Pending{done: make(chan struct{})}
+	if running, loaded := inflight.LoadOrStore(key, p); loaded {
+		return running.(*Pending)
+	}
+
+	go func() {
+		defer close(p.done)
+		defer inflight.Delete(key)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		p.message, p.err = generate(ctx)
+		if p.err != nil {
+			// nothing is cached so the next request tries again
+			log.Printf("Background message generation failed for %s: %v", key, p.err)
+			return
+		}
+		if err := put(ctx, p.message); err != nil {
+			log.Printf("Could not cache message for %s: %v", key, err)
+		}
+	}()
+	return p
+}
+
+// MemoryCache is a MessageCache kept in process, for tests and for running
+// without Postgres. it forgets everything on restart.
+type MemoryCache struct {
+	TTL time.Duration
+
+	mu       sync.Mutex
+	entries  map[CacheKey]memoryEntry
+	inflight sync.Map
+}
+
+type memoryEntry struct {
+	message   string
+	expiresAt time.Time
+}
+
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		TTL:     ttl,
+		entries: map[CacheKey]memoryEntry{},
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key CacheKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !time.Now().Before(e.expiresAt) {
+		return "", false
+	}
+	return e.message, true
+}
+
+func (c *MemoryCache) Put(_ context.Context, key CacheKey, _, message string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = memoryEntry{message: message, expiresAt: time.Now().Add(c.TTL)}
+	return nil
+}
+
+func (c *MemoryCache) Invalidate(_ context.Context, productIDs ...int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if slices.Contains(productIDs, key.ProductID) {
+			delete(c.entries, key)
+		}
+	}
+	return nil
+}
+
+func (c *MemoryCache) Fill(key CacheKey, provider string, timeout time.Duration, generate func(ctx context.Context) (string, error)) *Pending {
+	return fill(&c.inflight, key, timeout, generate, func(ctx context.Context, message string) error {
+		return c.Put(ctx, key, provider, message)
+	})
+}
@@ -0,0 +1,167 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ecoscan.com/config"
+)
+
+const geminiURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// Gemini calls Google's generateContent API
+type Gemini struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewGemini(cnf config.ProviderConfig) *Gemini {
+	baseURL := cnf.BaseURL
+	if baseURL == "" {
+		baseURL = geminiURL
+	}
+	return &Gemini{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  cnf.APIKey,
+		model:   cnf.Model,
+		client:  &http.Client{Timeout: timeoutOr(cnf.Timeout, 12*time.Second)},
+	}
+}
+
+func (g *Gemini) Name() string {
+	return "gemini"
+}
+
+func (g *Gemini) Generate(ctx context.Context, req Request) (string, error) {
+	httpReq, err := g.newRequest(ctx, req, ":generateContent")
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("gemini request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("gemini response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Provider: "gemini", Code: resp.StatusCode}
+	}
+
+	var result geminiResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("gemini response: %w", err)
+	}
+	result.fillUsage(req.Usage)
+	if text := result.text(); text != "" {
+		return text, nil
+	}
+	return "", fmt.Errorf("gemini returned no message")
+}
+
+// Stream uses streamGenerateContent, every event is a partial response
+func (g *Gemini) Stream(ctx context.Context, req Request, onChunk func(string)) (string, error) {
+	httpReq, err := g.newRequest(ctx, req, ":streamGenerateContent?alt=sse")
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("gemini request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Provider: "gemini", Code: resp.StatusCode}
+	}
+
+	var text strings.Builder
+	err = readEvents(resp.Body, func(data []byte) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("gemini stream: %w", err)
+		}
+		// every chunk reports the running total
+		chunk.fillUsage(req.Usage)
+		if t := chunk.text(); t != "" {
+			text.WriteString(t)
+			onChunk(t)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("gemini returned no message")
+	}
+	return text.String(), nil
+}
+
+func (g *Gemini) newRequest(ctx context.Context, req Request, method string) (*http.Request, error) {
+	payload := map[string]interface{}{
+		"contents": []map[string]interface{}{
+			{"parts": []map[string]string{{"text": req.Prompt}}},
+		},
+	}
+	bodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := g.baseURL + "/models/" + url.PathEscape(g.model) + method
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", g.apiKey)
+	return httpReq, nil
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func (r geminiResponse) fillUsage(usage *Usage) {
+	if r.UsageMetadata == nil || usage == nil {
+		return
+	}
+	usage.PromptTokens = r.UsageMetadata.PromptTokenCount
+	usage.CompletionTokens = r.UsageMetadata.CandidatesTokenCount
+}
+
+// text joins the parts of the first candidate
+func (r geminiResponse) text() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
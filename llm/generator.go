@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ecoscan.com/config"
+	"ecoscan.com/repo"
+	"github.com/jmoiron/sqlx"
+)
+
+// MessageGenerator writes the motivational message shown under a scanned product
+type MessageGenerator interface {
+	// Name identifies the provider in logs, e.g. "openrouter"
+	Name() string
+	Generate(ctx context.Context, req Request) (string, error)
+}
+
+// Request carries the rendered prompt plus the data template based generators need
+type Request struct {
+	Product repo.Product
+	Score   int
+	Prompt  string
+	// language the message must be written in, e.g. "bn"
+	Lang string
+	// when set, providers fill in the tokens the call consumed
+	Usage *Usage
+}
+
+// FromConfig builds the generator selected by MESSAGE_PROVIDER. model backed
+// providers are wrapped in the guardrails and the circuit breaker, retry and
+// budget layer, the templates are trusted as written and never fail.
+func FromConfig(cnf *config.Config, db *sqlx.DB) (MessageGenerator, error) {
+	resilient := func(next MessageGenerator, pcnf config.ProviderConfig) MessageGenerator {
+		return NewGuarded(&Resilient{
+			Next:    next,
+			Model:   pcnf.Model,
+			Breaker: NewBreaker(cnf.BreakerThreshold, cnf.BreakerCooldown),
+			Usage:   NewUsageStore(db),
+			Budget:  Budget{DailyRequests: pcnf.DailyRequests, DailyTokens: pcnf.DailyTokens},
+			Retries: cnf.MessageRetries,
+			Backoff: 500 * time.Millisecond,
+		})
+	}
+
+	switch cnf.MessageProvider {
+	case "openrouter":
+		return resilient(NewOpenRouter(cnf.OpenRouter), cnf.OpenRouter), nil
+	case "gemini":
+		return resilient(NewGemini(cnf.Gemini), cnf.Gemini), nil
+	case "openai":
+		return resilient(NewOpenAICompatible("openai", cnf.OpenAI), cnf.OpenAI), nil
+	case "template":
+		return Template{}, nil
+	default:
+		return nil, fmt.Errorf("unknown message provider %q", cnf.MessageProvider)
+	}
+}
+
+func timeoutOr(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,288 @@
+package llm
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"ecoscan.com/prompt"
+)
+
+/* providers are asked for three short lines ending in 🌱 but nothing forces
+them to comply. Guarded checks every message before it can be cached or shown
+and retries once, a second bad message is reported as ErrRejected so the
+caller serves a template instead */
+
+// ErrRejected means the provider's output failed the guardrails twice
+var ErrRejected = errors.New("message rejected by guardrails")
+
+type Guardrails struct {
+	Lines      int    `json:"lines"`
+	MaxChars   int    `json:"max_chars"`
+	Terminator string `json:"terminator"`
+	// share of letters that must be in the requested language's script
+	MinScriptShare float64 `json:"min_script_share"`
+	// no claimed percentage may exceed this, whatever the score
+	MaxPercent int `json:"max_percent"`
+	// case-insensitive phrases by language, "*" applies to all
+	Banned map[string][]string `json:"banned"`
+}
+
+//go:embed guardrails.json
+var guardrailsJSON []byte
+
+// DefaultGuardrails are the built-in rules from guardrails.json
+var DefaultGuardrails Guardrails
+
+func init() {
+	if err := json.Unmarshal(guardrailsJSON, &DefaultGuardrails); err != nil {
+		panic("llm: decoding guardrails: " + err.Error())
+	}
+}
+
+// Check tidies msg (trimmed, blank lines dropped) and validates it for req.
+// every failed rule is reported, not just the first.
+func (g Guardrails) Check(msg string, req Request) (string, error) {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(msg, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	msg = strings.Join(lines, "\n")
+
+	var errs []error
+	if msg == "" {
+		return "", errors.New("message is empty")
+	}
+	if g.Lines > 0 && len(lines) != g.Lines {
+		errs = append(errs, fmt.Errorf("has %d lines, want %d", len(lines), g.Lines))
+	}
+	if n := len([]rune(msg)); g.MaxChars > 0 && n > g.MaxChars {
+		errs = append(errs, fmt.Errorf("is %d characters long, the limit is %d", n, g.MaxChars))
+	}
+	if g.Terminator != "" && !strings.HasSuffix(strings.TrimRight(msg, " ।.!"), g.Terminator) {
+		errs = append(errs, fmt.Errorf("does not end with %s", g.Terminator))
+	}
+	if share := scriptShare(msg, req.Lang); share < g.MinScriptShare {
+		errs = append(errs, fmt.Errorf("only %.0f%% of its letters are in the %s script", share*100, req.Lang))
+	}
+	errs = append(errs, g.contentErrors(msg, req)...)
+
+	return msg, errors.Join(errs...)
+}
+
+// checkLine validates one complete, trimmed line of a message still being
+// written: the rules on what it says, and that it doesn't already break the
+// line or character limits of the whole message. lines and chars count the
+// message so far, this line included.
+func (g Guardrails) checkLine(line string, lines, chars int, req Request) error {
+	var errs []error
+	if g.Lines > 0 && lines > g.Lines {
+		errs = append(errs, fmt.Errorf("has more than %d lines", g.Lines))
+	}
+	if g.MaxChars > 0 && chars > g.MaxChars {
+		errs = append(errs, fmt.Errorf("is over %d characters long", g.MaxChars))
+	}
+	// a line of only emoji has no script to check
+	if share := scriptShare(line, req.Lang); share < g.MinScriptShare && strings.ContainsFunc(line, unicode.IsLetter) {
+		errs = append(errs, fmt.Errorf("only %.0f%% of its letters are in the %s script", share*100, req.Lang))
+	}
+	errs = append(errs, g.contentErrors(line, req)...)
+	return errors.Join(errs...)
+}
+
+// contentErrors checks what text says: banned phrases and claimed percentages
+func (g Guardrails) contentErrors(text string, req Request) []error {
+	var errs []error
+	lower := strings.ToLower(text)
+	for _, lang := range []string{"*", req.Lang} {
+		for _, phrase := range g.Banned[lang] {
+			if strings.Contains(lower, strings.ToLower(phrase)) {
+				errs = append(errs, fmt.Errorf("contains banned phrase %q", phrase))
+			}
+		}
+	}
+
+	limit := g.percentLimit(req.Score)
+	for _, pct := range percentages(text) {
+		if pct > limit {
+			errs = append(errs, fmt.Errorf("claims %d%%, more than a score of %d supports (%d%%)", pct, req.Score, limit))
+		}
+	}
+	return errs
+}
+
+// percentLimit is the largest saving a message may claim for a score: a low
+// scoring product can't save more than the room it leaves, a good one can't
+// claim more than the score itself. low is whatever got the "could save" prompt
+func (g Guardrails) percentLimit(score int) int {
+	limit := score
+	if score < prompt.LowScoreBelow {
+		limit = 100 - score
+	}
+	return min(limit, g.MaxPercent)
+}
+
+var percentPattern = regexp.MustCompile(`([0-9০-৯]+)(?:[.,][0-9০-৯]+)?\s*(?:%|％|শতাংশ|percent)`)
+
+// percentages finds every claimed percentage, in Latin or Bangla digits
+func percentages(msg string) []int {
+	var found []int
+	for _, m := range percentPattern.FindAllStringSubmatch(msg, -1) {
+		digits := strings.Map(func(r rune) rune {
+			if r >= '০' && r <= '৯' {
+				return '0' + (r - '০')
+			}
+			return r
+		}, m[1])
+		if n, err := strconv.Atoi(digits); err == nil {
+			found = append(found, n)
+		}
+	}
+	return found
+}
+
+// scriptShare is the share of letters written in lang's script. Bangla
+// messages mix in English words on purpose, so only the share is checked.
+func scriptShare(msg, lang string) float64 {
+	script := unicode.Latin
+	if lang == "bn" {
+		script = unicode.Bengali
+	}
+	var inScript, total int
+	for _, r := range msg {
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) {
+			continue
+		}
+		total++
+		if unicode.Is(script, r) {
+			inScript++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(inScript) / float64(total)
+}
+
+// Guarded validates another generator's output, retrying once
+type Guarded struct {
+	Next  MessageGenerator
+	Rules Guardrails
+}
+
+func NewGuarded(next MessageGenerator) *Guarded {
+	return &Guarded{Next: next, Rules: DefaultGuardrails}
+}
+
+func (g *Guarded) Name() string {
+	return g.Next.Name()
+}
+
+func (g *Guarded) Unwrap() MessageGenerator {
+	return g.Next
+}
+
+func (g *Guarded) Generate(ctx context.Context, req Request) (string, error) {
+	var lastErr error
+	for attempt := 1; attempt <= 2; attempt++ {
+		msg, err := g.Next.Generate(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		if msg, err = g.check(attempt, msg, req); err == nil {
+			return msg, nil
+		}
+		lastErr = err
+	}
+	return "", fmt.Errorf("%w: %v", ErrRejected, lastErr)
+}
+
+// Stream streams the first attempt when the provider can, a line at a time:
+// provider chunks are held back until a line is complete and has passed
+// checkLine, and the first line that fails ends the stream. rules on the whole
+// message can only be checked at the end, so callers must still show the
+// returned message in place of the streamed lines. the retry is not streamed.
+func (g *Guarded) Stream(ctx context.Context, req Request, onChunk func(string)) (string, error) {
+	s, ok := g.Next.(Streamer)
+	if !ok {
+		return g.Generate(ctx, req)
+	}
+
+	gate := &lineGate{rules: g.Rules, req: req, emit: onChunk}
+	msg, err := s.Stream(ctx, req, gate.write)
+	if err != nil {
+		return "", err
+	}
+	if msg, err = g.check(1, msg, req); err == nil {
+		return msg, nil
+	}
+
+	msg, err = g.Next.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if msg, err = g.check(2, msg, req); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+	return msg, nil
+}
+
+// lineGate sits between a provider's stream and the caller's onChunk, nothing
+// reaches the caller that checkLine hasn't passed
+type lineGate struct {
+	rules Guardrails
+	req   Request
+	emit  func(string)
+
+	pending string // the line being written
+	lines   int
+	chars   int
+	closed  bool
+}
+
+func (l *lineGate) write(chunk string) {
+	if l.closed {
+		return
+	}
+	text := l.pending + chunk
+	end := strings.LastIndexByte(text, '\n')
+	if end < 0 {
+		l.pending = text
+		return
+	}
+	l.pending = text[end+1:]
+
+	for _, line := range strings.Split(text[:end], "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		l.lines++
+		if l.lines > 1 {
+			l.chars++ // the newline Check joins lines with
+		}
+		l.chars += len([]rune(line))
+		if err := l.rules.checkLine(line, l.lines, l.chars, l.req); err != nil {
+			log.Printf("Stopped streaming message for %s at line %d: %v", l.req.Product.Barcode, l.lines, strings.ReplaceAll(err.Error(), "\n", "; "))
+			l.closed = true
+			return
+		}
+		l.emit(line + "\n")
+	}
+}
+
+func (g *Guarded) check(attempt int, msg string, req Request) (string, error) {
+	msg, err := g.Rules.Check(msg, req)
+	if err != nil {
+		log.Printf("%s message for %s rejected (attempt %d): %v", g.Next.Name(), req.Product.Barcode, attempt, strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+	return msg, err
+}
@@ -0,0 +1,184 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecoscan.com/config"
+)
+
+const openRouterURL = "https://openrouter.ai/api/v1"
+
+// OpenAICompatible talks to any /chat/completions API: OpenRouter, OpenAI or a local Ollama
+type OpenAICompatible struct {
+	name    string
+	baseURL string
+	apiKey  string
+	model   string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewOpenAICompatible(name string, cnf config.ProviderConfig) *OpenAICompatible {
+	return &OpenAICompatible{
+		name:    name,
+		baseURL: strings.TrimSuffix(cnf.BaseURL, "/"),
+		apiKey:  cnf.APIKey,
+		model:   cnf.Model,
+		headers: map[string]string{},
+		client:  &http.Client{Timeout: timeoutOr(cnf.Timeout, 30*time.Second)},
+	}
+}
+
+// NewOpenRouter is the OpenAI compatible client pointed at OpenRouter
+func NewOpenRouter(cnf config.ProviderConfig) *OpenAICompatible {
+	if cnf.BaseURL == "" {
+		cnf.BaseURL = openRouterURL
+	}
+	g := NewOpenAICompatible("openrouter", cnf)
+	g.client.Timeout = timeoutOr(cnf.Timeout, 12*time.Second)
+	g.headers["HTTP-Referer"] = "https://yourapp.com"
+	g.headers["X-Title"] = "ecoScanAi"
+	return g
+}
+
+func (g *OpenAICompatible) Name() string {
+	return g.name
+}
+
+func (g *OpenAICompatible) Generate(ctx context.Context, req Request) (string, error) {
+	httpReq, err := g.newRequest(ctx, req, false)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("%s request: %w", g.name, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("%s response: %w", g.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Provider: g.name, Code: resp.StatusCode}
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage *openAIUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("%s response: %w", g.name, err)
+	}
+	result.Usage.fill(req.Usage)
+	if len(result.Choices) == 0 || result.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("%s returned no message", g.name)
+	}
+	return result.Choices[0].Message.Content, nil
+}
+
+func (g *OpenAICompatible) newRequest(ctx context.Context, req Request, stream bool) (*http.Request, error) {
+	payload := map[string]interface{}{
+		"model": g.model,
+		"messages": []map[string]string{
+			{"role": "user", "content": req.Prompt},
+		},
+		"stream": stream,
+	}
+	if stream {
+		payload["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	bodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", g.baseURL+"/chat/completions", bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if g.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+g.apiKey)
+	}
+	for k, v := range g.headers {
+		httpReq.Header.Set(k, v)
+	}
+	return httpReq, nil
+}
+
+// Stream asks for server-sent chunks and hands each content delta to onChunk
+func (g *OpenAICompatible) Stream(ctx context.Context, req Request, onChunk func(string)) (string, error) {
+	httpReq, err := g.newRequest(ctx, req, true)
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("%s request: %w", g.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{Provider: g.name, Code: resp.StatusCode}
+	}
+
+	var text strings.Builder
+	err = readEvents(resp.Body, func(data []byte) error {
+		if string(data) == "[DONE]" {
+			return errStreamDone
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *openAIUsage `json:"usage"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("%s stream: %w", g.name, err)
+		}
+		// only the last chunk carries usage
+		chunk.Usage.fill(req.Usage)
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			text.WriteString(chunk.Choices[0].Delta.Content)
+			onChunk(chunk.Choices[0].Delta.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("%s returned no message", g.name)
+	}
+	return text.String(), nil
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (u *openAIUsage) fill(usage *Usage) {
+	if u == nil || usage == nil {
+		return
+	}
+	usage.PromptTokens = u.PromptTokens
+	usage.CompletionTokens = u.CompletionTokens
+}
@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// ErrBudgetExceeded means today's request or token budget is used up
+var ErrBudgetExceeded = errors.New("daily provider budget exceeded")
+
+// StatusError is a non-200 answer from a provider
+type StatusError struct {
+	Provider string
+	Code     int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.Provider, e.Code)
+}
+
+// Budget caps a provider's daily use, zero means unlimited
+type Budget struct {
+	DailyRequests int `json:"daily_requests"`
+	DailyTokens   int `json:"daily_tokens"`
+}
+
+// Resilient guards a provider with a circuit breaker, retries transient
+// failures with jittered backoff, enforces the daily budget and records every
+// call in the usage store
+type Resilient struct {
+	Next    MessageGenerator
+	Model   string
+	Breaker *Breaker
+	Usage   *UsageStore
+	Budget  Budget
+	// extra attempts after a transient failure
+	Retries int
+	// first backoff, doubled for every retry
+	Backoff time.Duration
+}
+
+func (r *Resilient) Name() string {
+	return r.Next.Name()
+}
+
+func (r *Resilient) Unwrap() MessageGenerator {
+	return r.Next
+}
+
+func (r *Resilient) Generate(ctx context.Context, req Request) (string, error) {
+	for attempt := 0; ; attempt++ {
+		msg, err := r.call(ctx, req, func(req Request) (string, error) {
+			return r.Next.Generate(ctx, req)
+		})
+		if err == nil || attempt >= r.Retries || !retryable(ctx, err) {
+			return msg, err
+		}
+		if err := r.wait(ctx, attempt); err != nil {
+			return "", err
+		}
+	}
+}
+
+// Stream retries only while nothing has been streamed yet, a half written
+// message can't be taken back
+func (r *Resilient) Stream(ctx context.Context, req Request, onChunk func(string)) (string, error) {
+	s, ok := r.Next.(Streamer)
+	if !ok {
+		return r.Generate(ctx, req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		streamed := false
+		msg, err := r.call(ctx, req, func(req Request) (string, error) {
+			return s.Stream(ctx, req, func(chunk string) {
+				streamed = true
+				onChunk(chunk)
+			})
+		})
+		if err == nil || streamed || attempt >= r.Retries || !retryable(ctx, err) {
+			return msg, err
+		}
+		if err := r.wait(ctx, attempt); err != nil {
+			return "", err
+		}
+	}
+}
+
+// call makes one attempt: budget and breaker first, then the call, then the books
+func (r *Resilient) call(ctx context.Context, req Request, do func(Request) (string, error)) (string, error) {
+	if err := r.checkBudget(ctx); err != nil {
+		return "", err
+	}
+	if err := r.Breaker.Allow(); err != nil {
+		return "", err
+	}
+
+	var usage Usage
+	req.Usage = &usage
+	msg, err := do(req)
+
+	// a caller that gave up says nothing about the provider
+	if err != nil && ctx.Err() != nil {
+		r.Breaker.Release()
+	} else {
+		r.Breaker.Record(err)
+	}
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if rerr := r.Usage.Record(recordCtx, r.Name(), r.Model, usage, err != nil); rerr != nil {
+		log.Printf("Could not record %s usage: %v", r.Name(), rerr)
+	}
+	return msg, err
+}
+
+func (r *Resilient) checkBudget(ctx context.Context) error {
+	if r.Budget.DailyRequests <= 0 && r.Budget.DailyTokens <= 0 {
+		return nil
+	}
+	today, err := r.Usage.Today(ctx, r.Name(), r.Model)
+	if err != nil {
+		// counters we can't read shouldn't take messages down with them
+		log.Printf("Could not read %s usage, skipping budget check: %v", r.Name(), err)
+		return nil
+	}
+	if r.Budget.DailyRequests > 0 && today.Requests >= r.Budget.DailyRequests {
+		return fmt.Errorf("%w: %d of %d requests", ErrBudgetExceeded, today.Requests, r.Budget.DailyRequests)
+	}
+	if r.Budget.DailyTokens > 0 && today.Tokens() >= r.Budget.DailyTokens {
+		return fmt.Errorf("%w: %d of %d tokens", ErrBudgetExceeded, today.Tokens(), r.Budget.DailyTokens)
+	}
+	return nil
+}
+
+// wait sleeps Backoff * 2^attempt, half of it jittered, or until ctx ends
+func (r *Resilient) wait(ctx context.Context, attempt int) error {
+	d := r.Backoff << attempt
+	d = d/2 + rand.N(d/2+1)
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryable is true for rate limits, server errors and network failures
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrBudgetExceeded) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code == 429 || status.Code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// ProviderStats is what the admin stats endpoint shows for a provider
+type ProviderStats struct {
+	Provider            string       `json:"provider"`
+	Model               string       `json:"model"`
+	Circuit             BreakerState `json:"circuit"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	NextProbeAt         *time.Time   `json:"next_probe_at,omitempty"`
+	Budget              Budget       `json:"budget"`
+	Today               DailyUsage   `json:"today"`
+	History             []DailyUsage `json:"history"`
+}
+
+// StatsReporter is implemented by generators that track their provider's health
+type StatsReporter interface {
+	Stats(ctx context.Context) (ProviderStats, error)
+}
+
+func (r *Resilient) Stats(ctx context.Context) (ProviderStats, error) {
+	state, failures, probeAt := r.Breaker.State()
+	stats := ProviderStats{
+		Provider:            r.Name(),
+		Model:               r.Model,
+		Circuit:             state,
+		ConsecutiveFailures: failures,
+		Budget:              r.Budget,
+	}
+	if !probeAt.IsZero() {
+		stats.NextProbeAt = &probeAt
+	}
+
+	var err error
+	if stats.Today, err = r.Usage.Today(ctx, r.Name(), r.Model); err != nil {
+		return stats, err
+	}
+	stats.History, err = r.Usage.History(ctx, r.Name(), r.Model, 30)
+	return stats, err
+}
+
+// StatsOf finds the StatsReporter behind any wrapping generators
+func StatsOf(g MessageGenerator) (StatsReporter, bool) {
+	for {
+		if s, ok := g.(StatsReporter); ok {
+			return s, true
+		}
+		u, ok := g.(interface{ Unwrap() MessageGenerator })
+		if !ok {
+			return nil, false
+		}
+		g = u.Unwrap()
+	}
+}
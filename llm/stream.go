@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+)
+
+// Streamer is implemented by generators that can hand out the message while it
+// is being written. onChunk gets every piece as it arrives, the full message is
+// returned at the end.
+type Streamer interface {
+	Stream(ctx context.Context, req Request, onChunk func(string)) (string, error)
+}
+
+// errStreamDone lets an event handler end the stream early without an error
+var errStreamDone = errors.New("stream done")
+
+// readEvents calls onData with the data of every server-sent event in r
+func readEvents(r io.Reader, onData func([]byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			if len(data) > 0 {
+				if err := onData(data); err != nil {
+					if errors.Is(err, errStreamDone) {
+						return nil
+					}
+					return err
+				}
+				data = nil
+			}
+			continue
+		}
+		if value, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, bytes.TrimPrefix(value, []byte(" "))...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		if err := onData(data); err != nil && !errors.Is(err, errStreamDone) {
+			return err
+		}
+	}
+	return nil
+}
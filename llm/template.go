@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// fallback sets by language, every language needs both a low and a good set
+var lowScoreFallbacks = map[string][]string{
+	"bn": {
+		"বাহ, %s খেলে সত্যিই রিফ্রেশিং লাগে 🌱\nতবে প্লাস্টিক বোতলটা পরিবেশের জন্য ভালো নয়।\nআপনি যদি ক্যান নিতেন, প্রায় ৩০%% বর্জ্য কমানো যেতো।",
+		"%s ব্যবহার করলে মজা আছে 🌱\nকিন্তু এর প্যাকেজিংটা টেকসই নয়।\nআপনি যদি কাচ বা ক্যান বেছে নিতেন, প্রায় ২৫%% সেভ করতে পারতেন।",
+		"%s খাওয়া দারুণ লাগে 🌱\nকিন্তু প্লাস্টিক বোতলটা প্রকৃতির ক্ষতি করে।\nআপনি যদি বিকল্প নিতেন, waste reduction দ্বিগুণ হতো।",
+	},
+	"en": {
+		"%s is a real treat 🌱\nBut its packaging is hard on the environment.\nCheck the alternatives below, a greener pick can cut your waste by about 30%%.",
+		"Enjoying %s is easy 🌱\nIts packaging just isn't built to last a second life.\nPicking glass or a can next time could save around 25%% of the waste.",
+		"%s tastes great 🌱\nThe plastic it comes in stays in nature for a long time.\nOne of the alternatives below would make a real difference.",
+	},
+}
+
+var goodScoreFallbacks = map[string][]string{
+	"bn": {
+		"চমৎকার! %s বেছে নিয়ে আপনি দারুণ কাজ করেছেন 🌱\nএই প্যাকেজিংটা তুলনামূলকভাবে পরিবেশবান্ধব।\nএভাবে প্রায় ৪০%% বর্জ্য কমছে।",
+		"%s নেওয়ায় আপনি পরিবেশকে সাহায্য করছেন 🌱\nএটা সত্যিই অনুপ্রেরণাদায়ক একটি সিদ্ধান্ত।\nএভাবে প্রায় ৩৫%% waste সেভ হচ্ছে।",
+		"%s কিনে আপনি পৃথিবীকে একটু হালকা করেছেন 🌱\nএটা sustainable choice, ভবিষ্যতের জন্য ভালো।\nএভাবে প্রায় ৪৫%% সেভ হচ্ছে।",
+	},
+	"en": {
+		"Great pick! %s is a choice to feel good about 🌱\nIts packaging is kinder to the environment than most.\nChoices like this cut waste by around 40%%.",
+		"By choosing %s you are helping the planet 🌱\nThat is a genuinely inspiring decision.\nKeep it up, it saves around 35%% of the waste.",
+		"%s makes the world a little lighter 🌱\nIt's a sustainable choice that is good for the future.\nThis way around 45%% of the waste is saved.",
+	},
+}
+
+// Template picks one of the canned messages without calling out anywhere.
+// the choice is keyed on the barcode so a product always gets the same one.
+type Template struct{}
+
+func (Template) Name() string {
+	return "template"
+}
+
+func (t Template) Generate(_ context.Context, req Request) (string, error) {
+	return t.Message(req.Product.Barcode, req.Product.Name, req.Score, req.Lang), nil
+}
+
+// Message is Generate for callers that need a fallback that can't fail.
+// languages without a fallback set get the Bangla one.
+func (Template) Message(barcode, productName string, score int, lang string) string {
+	sets := goodScoreFallbacks
+	if score < 50 {
+		sets = lowScoreFallbacks
+	}
+	set, ok := sets[lang]
+	if !ok {
+		set = sets["bn"]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(barcode))
+	return fmt.Sprintf(set[h.Sum32()%uint32(len(set))], productName)
+}
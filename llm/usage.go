@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Usage is what one provider call consumed, providers fill in what their API reports
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// DailyUsage is one row of llm_usage
+type DailyUsage struct {
+	Day              time.Time `json:"day" db:"day"`
+	Provider         string    `json:"provider" db:"provider"`
+	Model            string    `json:"model" db:"model"`
+	Requests         int       `json:"requests" db:"requests"`
+	Failures         int       `json:"failures" db:"failures"`
+	PromptTokens     int       `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens" db:"completion_tokens"`
+}
+
+func (u DailyUsage) Tokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageStore keeps per day counters for every provider and model in llm_usage
+type UsageStore struct {
+	DB *sqlx.DB
+}
+
+func NewUsageStore(db *sqlx.DB) *UsageStore {
+	return &UsageStore{DB: db}
+}
+
+func (s *UsageStore) Record(ctx context.Context, provider, model string, u Usage, failed bool) error {
+	failures := 0
+	if failed {
+		failures = 1
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO llm_usage (day, provider, model, requests, failures, prompt_tokens, completion_tokens)
+		VALUES (CURRENT_DATE, $1, $2, 1, $3, $4, $5)
+		ON CONFLICT (day, provider, model) DO UPDATE SET
+			requests = llm_usage.requests + 1,
+			failures = llm_usage.failures + EXCLUDED.failures,
+			prompt_tokens = llm_usage.prompt_tokens + EXCLUDED.prompt_tokens,
+			completion_tokens = llm_usage.completion_tokens + EXCLUDED.completion_tokens
+	`, provider, model, failures, u.PromptTokens, u.CompletionTokens)
+	return err
+}
+
+// Today returns today's counters, zero when nothing was recorded yet
+func (s *UsageStore) Today(ctx context.Context, provider, model string) (DailyUsage, error) {
+	usage := DailyUsage{Provider: provider, Model: model}
+	err := s.DB.GetContext(ctx, &usage, `
+		SELECT day, provider, model, requests, failures, prompt_tokens, completion_tokens
+		FROM llm_usage
+		WHERE day = CURRENT_DATE AND provider = $1 AND model = $2
+	`, provider, model)
+	if errors.Is(err, sql.ErrNoRows) {
+		return usage, nil
+	}
+	return usage, err
+}
+
+// History returns the last days of counters, newest first
+func (s *UsageStore) History(ctx context.Context, provider, model string, days int) ([]DailyUsage, error) {
+	history := []DailyUsage{}
+	err := s.DB.SelectContext(ctx, &history, `
+		SELECT day, provider, model, requests, failures, prompt_tokens, completion_tokens
+		FROM llm_usage
+		WHERE provider = $1 AND model = $2 AND day > CURRENT_DATE - $3::int
+		ORDER BY day DESC
+	`, provider, model, days)
+	return history, err
+}
@@ -0,0 +1,348 @@
+package logic
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ecoscan.com/geo"
+	"ecoscan.com/taxonomy"
+)
+
+//go:embed rules/default.json
+var defaultRules []byte
+
+// Rulebook holds every tunable number used by the scoring functions
+type Rulebook struct {
+	Version string `json:"version"`
+	// below this share of known inputs a score is rated as insufficient data
+	MinConfidence float64        `json:"min_confidence"`
+	Weights       Weights        `json:"weights"`
+	Packaging     PackagingTable `json:"packaging"`
+	Transport     TransportTable `json:"transport"`
+	Disposal      ValueTable     `json:"disposal"`
+	Ratings       RatingRules    `json:"ratings"`
+
+	fingerprint string
+	home        geo.Place
+	// Ratings.Categories keyed in lower case
+	categoryBands map[string][]RatingBand
+}
+
+// MaxVersionLength keeps Fingerprint within product_scores.scoring_version
+const MaxVersionLength = 41
+
+type Weights struct {
+	Packaging float64 `json:"packaging"`
+	Transport float64 `json:"transport"`
+	Disposal  float64 `json:"disposal"`
+}
+
+// ValueTable maps an attribute value to a 0-100 sub-score
+type ValueTable struct {
+	Default int            `json:"default"`
+	Values  map[string]int `json:"values"`
+}
+
+// PackagingTable scores each packaging material, components marked
+// recyclable get RecyclableBonus on top of their material's value
+type PackagingTable struct {
+	ValueTable
+	RecyclableBonus int `json:"recyclable_bonus"`
+}
+
+// TransportTable scores by distance when the location can be geocoded and
+// falls back to the local/national/... value table when it can't
+type TransportTable struct {
+	ValueTable
+	// where stored scores are measured from when the user gives no location
+	HomeRegion    string         `json:"home_region"`
+	DistanceBands []DistanceBand `json:"distance_bands"`
+}
+
+// DistanceBand applies up to MaxKm, a MaxKm of 0 on the last band means no limit
+type DistanceBand struct {
+	MaxKm float64 `json:"max_km"`
+	Score int     `json:"score"`
+}
+
+func (t TransportTable) scoreDistance(km float64) int {
+	for _, b := range t.DistanceBands {
+		if b.MaxKm == 0 || km <= b.MaxKm {
+			return b.Score
+		}
+	}
+	return t.DistanceBands[len(t.DistanceBands)-1].Score
+}
+
+// RatingRules turns a score into a label. Categories are keyed by "Category"
+// or "Category/Sub Category" (case-insensitive), the more specific key wins.
+type RatingRules struct {
+	Default    []RatingBand            `json:"default"`
+	Categories map[string][]RatingBand `json:"categories,omitempty"`
+}
+
+// RatingBand applies to scores up to and including MaxScore
+type RatingBand struct {
+	MaxScore int    `json:"max_score"`
+	Label    string `json:"label"`
+}
+
+const (
+	// NotRated is the label for products without a usable score
+	NotRated = "Not Rated"
+	// InsufficientData is the label for scores that are mostly defaults
+	InsufficientData = "Insufficient data"
+)
+
+// Rating labels a score with the thresholds for the product's category
+func (rb *Rulebook) Rating(score int, confidence float64, category, subCategory string) string {
+	if score <= 0 {
+		return NotRated
+	}
+	if confidence < rb.MinConfidence {
+		return InsufficientData
+	}
+	for _, band := range rb.ratingBands(category, subCategory) {
+		if score <= band.MaxScore {
+			return band.Label
+		}
+	}
+	return NotRated
+}
+
+func (rb *Rulebook) ratingBands(category, subCategory string) []RatingBand {
+	if bands, ok := rb.categoryBands[strings.ToLower(category+"/"+subCategory)]; ok {
+		return bands
+	}
+	if bands, ok := rb.categoryBands[strings.ToLower(category)]; ok {
+		return bands
+	}
+	return rb.Ratings.Default
+}
+
+func validateRatingBands(name string, bands []RatingBand) []error {
+	var errs []error
+	if len(bands) == 0 {
+		return []error{fmt.Errorf("%s must not be empty", name)}
+	}
+	for i, b := range bands {
+		if b.Label == "" {
+			errs = append(errs, fmt.Errorf("%s[%d].label is required", name, i))
+		}
+		if i > 0 && b.MaxScore <= bands[i-1].MaxScore {
+			errs = append(errs, fmt.Errorf("%s must be ordered by max_score", name))
+		}
+	}
+	if bands[len(bands)-1].MaxScore < 100 {
+		errs = append(errs, fmt.Errorf("%s must cover scores up to 100", name))
+	}
+	return errs
+}
+
+// lookup reports false when the default had to be used
+func (t ValueTable) lookup(value string) (int, bool) {
+	if score, ok := t.Values[value]; ok {
+		return score, true
+	}
+	return t.Default, false
+}
+
+// Fingerprint is the version plus a short content hash, so two files that
+// forgot to bump the version still produce distinguishable scores
+func (rb *Rulebook) Fingerprint() string {
+	return rb.fingerprint
+}
+
+// Home is the resolved home region stored scores are measured from
+func (rb *Rulebook) Home() geo.Place {
+	return rb.home
+}
+
+func (rb *Rulebook) Validate() error {
+	var errs []error
+	if rb.Version == "" {
+		errs = append(errs, errors.New("version is required"))
+	}
+	if len(rb.Version) > MaxVersionLength {
+		errs = append(errs, fmt.Errorf("version must be at most %d characters", MaxVersionLength))
+	}
+
+	if rb.MinConfidence < 0 || rb.MinConfidence > 1 {
+		errs = append(errs, errors.New("min_confidence must be between 0 and 1"))
+	}
+
+	w := rb.Weights
+	if w.Packaging < 0 || w.Transport < 0 || w.Disposal < 0 {
+		errs = append(errs, errors.New("weights must not be negative"))
+	}
+	if sum := w.Packaging + w.Transport + w.Disposal; math.Abs(sum-1) > 0.001 {
+		errs = append(errs, fmt.Errorf("weights must add up to 1, got %.3f", sum))
+	}
+
+	for name, t := range map[string]struct {
+		kind  taxonomy.Kind
+		table ValueTable
+	}{
+		"packaging": {taxonomy.PackagingMaterial, rb.Packaging.ValueTable},
+		"transport": {taxonomy.ManufacturingLocation, rb.Transport.ValueTable},
+		"disposal":  {taxonomy.DisposalMethod, rb.Disposal},
+	} {
+		table := t.table
+		if table.Default < 0 || table.Default > 100 {
+			errs = append(errs, fmt.Errorf("%s.default must be between 0 and 100", name))
+		}
+		if len(table.Values) == 0 {
+			errs = append(errs, fmt.Errorf("%s.values must not be empty", name))
+		}
+		for value, score := range table.Values {
+			if value == "" {
+				errs = append(errs, fmt.Errorf("%s.values has an empty key", name))
+			}
+			if value != "" && !taxonomy.Known(t.kind, value) {
+				errs = append(errs, fmt.Errorf("%s.values[%q] is not a %s code", name, value, t.kind))
+			}
+			if score < 0 || score > 100 {
+				errs = append(errs, fmt.Errorf("%s.values[%q] must be between 0 and 100", name, value))
+			}
+		}
+	}
+
+	if rb.Packaging.RecyclableBonus < 0 || rb.Packaging.RecyclableBonus > 100 {
+		errs = append(errs, errors.New("packaging.recyclable_bonus must be between 0 and 100"))
+	}
+	if _, ok := geo.Resolve(rb.Transport.HomeRegion); !ok {
+		errs = append(errs, fmt.Errorf("transport.home_region %q is not in the gazetteer", rb.Transport.HomeRegion))
+	}
+	bands := rb.Transport.DistanceBands
+	if len(bands) == 0 {
+		errs = append(errs, errors.New("transport.distance_bands must not be empty"))
+	}
+	for i, b := range bands {
+		if b.Score < 0 || b.Score > 100 {
+			errs = append(errs, fmt.Errorf("transport.distance_bands[%d].score must be between 0 and 100", i))
+		}
+		last := i == len(bands)-1
+		if b.MaxKm < 0 || (b.MaxKm == 0 && !last) {
+			errs = append(errs, fmt.Errorf("transport.distance_bands[%d].max_km must be positive, only the last band may be 0", i))
+		}
+		if i > 0 && b.MaxKm != 0 && b.MaxKm <= bands[i-1].MaxKm {
+			errs = append(errs, fmt.Errorf("transport.distance_bands must be ordered by max_km"))
+		}
+	}
+
+	errs = append(errs, validateRatingBands("ratings.default", rb.Ratings.Default)...)
+	seen := map[string]string{}
+	for key, bands := range rb.Ratings.Categories {
+		errs = append(errs, validateRatingBands(fmt.Sprintf("ratings.categories[%q]", key), bands)...)
+		// keys match case-insensitively, two that differ only in case would race
+		if other, ok := seen[strings.ToLower(key)]; ok {
+			errs = append(errs, fmt.Errorf("ratings.categories has both %q and %q", other, key))
+		}
+		seen[strings.ToLower(key)] = key
+	}
+
+	return errors.Join(errs...)
+}
+
+// ParseRulebook decodes and validates a rulebook, unknown fields are rejected
+// so a typo in a key doesn't silently fall back to zero
+func ParseRulebook(data []byte) (*Rulebook, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var rb Rulebook
+	if err := dec.Decode(&rb); err != nil {
+		return nil, fmt.Errorf("decoding rulebook: %w", err)
+	}
+	if err := rb.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rulebook %q: %w", rb.Version, err)
+	}
+
+	rb.home, _ = geo.Resolve(rb.Transport.HomeRegion)
+	rb.categoryBands = map[string][]RatingBand{}
+	for key, bands := range rb.Ratings.Categories {
+		rb.categoryBands[strings.ToLower(key)] = bands
+	}
+	sum := sha256.Sum256(data)
+	rb.fingerprint = rb.Version + "@" + hex.EncodeToString(sum[:])[:8]
+	return &rb, nil
+}
+
+// LoadRulebook reads a rulebook file, an empty path gives the built-in defaults
+func LoadRulebook(path string) (*Rulebook, error) {
+	if path == "" {
+		return ParseRulebook(defaultRules)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rulebook: %w", err)
+	}
+	return ParseRulebook(data)
+}
+
+var current atomic.Pointer[Rulebook]
+
+func init() {
+	rb, err := ParseRulebook(defaultRules)
+	if err != nil {
+		panic("built-in scoring rules are invalid: " + err.Error())
+	}
+	current.Store(rb)
+}
+
+// Rules returns the rulebook scores are currently computed with
+func Rules() *Rulebook {
+	return current.Load()
+}
+
+func SetRules(rb *Rulebook) {
+	current.Store(rb)
+}
+
+// WatchRules polls the rulebook file and swaps it in when its content changes.
+// a file that fails validation is logged and the previous rules stay active.
+func WatchRules(ctx context.Context, path string, interval time.Duration, onChange func(*Rulebook)) {
+	if path == "" {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			rb, err := LoadRulebook(path)
+			if err != nil {
+				log.Printf("Scoring rules reload failed, keeping %s: %v", Rules().Fingerprint(), err)
+				continue
+			}
+			if rb.Fingerprint() == Rules().Fingerprint() {
+				continue
+			}
+
+			log.Printf("Scoring rules changed: %s -> %s", Rules().Fingerprint(), rb.Fingerprint())
+			SetRules(rb)
+			if onChange != nil {
+				onChange(rb)
+			}
+		}
+	}()
+}
@@ -1,60 +1,248 @@
 package logic
 
 import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"ecoscan.com/geo"
 	"ecoscan.com/repo"
+	"ecoscan.com/taxonomy"
 )
 
 /* the scoring system is totally a protype version. it doesnt means or represent the actual scoring features at all. The main roduct will have different and will follow a standard.*/
 
-func CalculateScore(product repo.Product) float64{
-	packagingScore := calculatePackagingScore(product.PackagingMaterial)
-	transportScore := calculateTransportScore(product.ManufacturingLocation)
-	disposalScore := calculateDisposalScore(product.DisposalMethod)
+func CalculateScore(product repo.Product) float64 {
+	return calculateScore(Rules(), product)
+}
 
-	overallScore := (float64(packagingScore) * 0.35) + (float64(transportScore) * 0.30) + (float64(disposalScore) * 0.35)
+// Evaluate scores a product with the active rulebook and returns the result ready to be persisted
+func Evaluate(product repo.Product) repo.Score {
+	return EvaluateWith(Rules(), product)
+}
 
-	return overallScore
+// Origin is where transport distance is measured from
+type Origin struct {
+	Point geo.Point
+	Label string
 }
 
-func calculatePackagingScore(material string) int {
-	switch material {
-	case "none", "compostable_paper":
-		return 100
-	case "glass", "paper", "cardboard":
-		return 80
-	case "aluminum", "recyclable_plastic":
-		return 60
-	case "plastic", "mixed_materials":
-		return 20
-	default:
-		return 40 //for unknown material
+// HomeOrigin is the rulebook's home region, stored scores are measured from it
+func HomeOrigin(rb *Rulebook) Origin {
+	return Origin{Point: rb.Home().Point(), Label: rb.Home().Label()}
+}
+
+// EvaluateWith scores a product against a specific rulebook from its home region
+func EvaluateWith(rb *Rulebook, product repo.Product) repo.Score {
+	return EvaluateFrom(rb, product, HomeOrigin(rb))
+}
+
+// EvaluateFrom scores a product for a user at origin, the result is not meant to be persisted
+func EvaluateFrom(rb *Rulebook, product repo.Product, origin Origin) repo.Score {
+	breakdown := ExplainFrom(rb, product, origin)
+	return repo.Score{
+		ProductID:      product.ID,
+		Score:          int(weightedTotal(breakdown)),
+		PackagingScore: breakdown[0].Score,
+		LocationScore:  breakdown[1].Score,
+		DisposalScore:  breakdown[2].Score,
+		Breakdown:      breakdown,
+		Confidence:     Confidence(breakdown),
+		Version:        rb.Fingerprint(),
+		ComputedAt:     time.Now(),
 	}
 }
 
-func calculateTransportScore(location string) int {
-	switch location {
-	case "local", "regional":
-		return 95
-	case "national":
-		return 70
-	case "international":
-		return 30
-	default:
-		return 50
+// Explain returns the packaging, transport and disposal components in that order
+func Explain(rb *Rulebook, product repo.Product) repo.ScoreBreakdown {
+	return ExplainFrom(rb, product, HomeOrigin(rb))
+}
+
+func ExplainFrom(rb *Rulebook, product repo.Product, origin Origin) repo.ScoreBreakdown {
+	return repo.ScoreBreakdown{
+		explainPackaging(rb.Packaging, rb.Weights.Packaging, product),
+		explainTransport(rb.Transport, rb.Weights.Transport, product.ManufacturingLocation, origin),
+		explainComponent("disposal", taxonomy.DisposalMethod, rb.Disposal, rb.Weights.Disposal, product.DisposalMethod),
 	}
 }
 
-func calculateDisposalScore(method string) int {
-	switch method {
-	case "compostable", "reusable": 
-		return 100 
-	case "recyclable": 
-		return 85 
-	case "minimal_impact":
-		return 70 
-	case "landfill":
-		return 10 
+// explainPackaging averages the component scores weighted by mass, so a heavy
+// glass bottle counts for more than its plastic cap. without components the
+// single packaging_material value is scored as before.
+func explainPackaging(table PackagingTable, weight float64, product repo.Product) repo.ScoreComponent {
+	components := product.PackagingComponents
+	if len(components) == 0 {
+		return explainComponent("packaging", taxonomy.PackagingMaterial, table.ValueTable, weight, product.PackagingMaterial)
+	}
+
+	totalMass := 0.0
+	for _, c := range components {
+		totalMass += c.MassGrams
+	}
+
+	parts := make([]repo.PartScore, 0, len(components))
+	values := make([]string, 0, len(components))
+	total, knownShare, defaulted, unmapped := 0.0, 0.0, 0, false
+	worst := -1
+	worstLoss := 0.0
+	for i, c := range components {
+		code, mapped := taxonomy.Normalize(taxonomy.PackagingMaterial, c.Material)
+		score, known := table.Default, false
+		if mapped {
+			score, known = table.lookup(code)
+		}
+		if c.Recyclable {
+			score = min(100, score+table.RecyclableBonus)
+		}
+
+		// parts without a recorded mass share equally
+		share := 1 / float64(len(components))
+		if totalMass > 0 {
+			share = c.MassGrams / totalMass
+		}
+
+		total += float64(score) * share
+		if known {
+			knownShare += share
+		} else {
+			defaulted++
+		}
+		if !mapped && strings.TrimSpace(c.Material) != "" {
+			unmapped = true
+		}
+		if loss := float64(100-score) * share; loss > worstLoss {
+			worst, worstLoss = i, loss
+		}
+
+		parts = append(parts, repo.PartScore{
+			Component:  c.Component,
+			Material:   c.Material,
+			Code:       code,
+			MassGrams:  c.MassGrams,
+			Recyclable: c.Recyclable,
+			Score:      score,
+			Share:      math.Round(share*1000) / 1000,
+			Defaulted:  !known,
+			Unmapped:   !mapped && strings.TrimSpace(c.Material) != "",
+		})
+		values = append(values, c.Component+": "+c.Material)
+	}
+
+	score := int(math.Round(total))
+	reason := fmt.Sprintf("Packaging scored across %d parts by mass", len(components))
+	if worst >= 0 {
+		p := parts[worst]
+		reason += fmt.Sprintf(", the %s (%s, %d out of 100, %.0f%% of the mass) costs the most", p.Component, p.Material, p.Score, p.Share*100)
+	}
+
+	return repo.ScoreComponent{
+		Name:      "packaging",
+		Score:     score,
+		Weight:    weight,
+		Value:     strings.Join(values, ", "),
+		Defaulted: defaulted == len(components),
+		Unmapped:  unmapped,
+		Certainty: math.Round(knownShare*100) / 100,
+		Reason:    reason,
+		Parts:     parts,
+	}
+}
+
+// explainTransport scores by distance from origin when the gazetteer knows the
+// location, a country level match is never treated as closer than its precision
+func explainTransport(table TransportTable, weight float64, value string, origin Origin) repo.ScoreComponent {
+	place, ok := geo.Resolve(value)
+	if !ok {
+		return explainComponent("transport", taxonomy.ManufacturingLocation, table.ValueTable, weight, value)
+	}
+
+	km := math.Max(geo.Distance(origin.Point, place.Point()), place.PrecisionKm)
+	score := table.scoreDistance(km)
+	return repo.ScoreComponent{
+		Name:       "transport",
+		Score:      score,
+		Weight:     weight,
+		Value:      value,
+		Code:       place.ID,
+		DistanceKm: math.Round(km),
+		Certainty:  1,
+		Reason:     fmt.Sprintf("Made in %s, about %.0f km from %s, scores %d out of 100", place.Label(), km, origin.Label, score),
+	}
+}
+
+// explainComponent maps the raw value onto the taxonomy first, the rulebook
+// is keyed by canonical codes only
+func explainComponent(name string, kind taxonomy.Kind, table ValueTable, weight float64, value string) repo.ScoreComponent {
+	label := strings.ReplaceAll(string(kind), "_", " ")
+	code, mapped := taxonomy.Normalize(kind, value)
+
+	score, known := table.Default, false
+	if mapped {
+		score, known = table.lookup(code)
+	}
+
+	var reason string
+	switch {
+	case strings.TrimSpace(value) == "":
+		reason = fmt.Sprintf("No %s recorded, the default of %d was used", label, score)
+	case !mapped:
+		reason = fmt.Sprintf("%s %q is not in the vocabulary, the default of %d was used", capitalize(label), value, score)
+	case !known:
+		reason = fmt.Sprintf("%s %q has no value in the rulebook, the default of %d was used", capitalize(label), code, score)
 	default:
-		return 40
+		reason = fmt.Sprintf("%s %q scores %d out of 100", capitalize(label), code, score)
+	}
+
+	return repo.ScoreComponent{
+		Name:      name,
+		Score:     score,
+		Weight:    weight,
+		Value:     value,
+		Code:      code,
+		Defaulted: !known,
+		Unmapped:  !mapped && strings.TrimSpace(value) != "",
+		Certainty: certainty(known),
+		Reason:    reason,
+	}
+}
+
+// Confidence is the weighted share of inputs that were actually known, from 0
+// (everything defaulted) to 1 (nothing defaulted)
+func Confidence(breakdown repo.ScoreBreakdown) float64 {
+	known, total := 0.0, 0.0
+	for _, c := range breakdown {
+		known += c.Certainty * c.Weight
+		total += c.Weight
+	}
+	if total == 0 {
+		return 0
 	}
-}
\ No newline at end of file
+	return math.Round(known/total*100) / 100
+}
+
+func calculateScore(rb *Rulebook, product repo.Product) float64 {
+	return weightedTotal(Explain(rb, product))
+}
+
+func weightedTotal(breakdown repo.ScoreBreakdown) float64 {
+	overallScore := 0.0
+	for _, c := range breakdown {
+		overallScore += float64(c.Score) * c.Weight
+	}
+	return overallScore
+}
+
+func certainty(known bool) float64 {
+	if known {
+		return 1
+	}
+	return 0
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Log is the mailer for development. it prints each mail to the server log,
+// links included, or when Dir is set writes it there as an .eml file any mail
+// client opens
+type Log struct {
+	Dir string
+}
+
+func (l *Log) Name() string {
+	if l.Dir != "" {
+		return "file (" + l.Dir + ")"
+	}
+	return "log"
+}
+
+func (l *Log) Send(_ context.Context, m Message) error {
+	if l.Dir == "" {
+		log.Printf("Mail to %s: %s\n%s", m.To, m.Subject, m.Text)
+		return nil
+	}
+
+	msg, err := compose("EcoScan <no-reply@localhost>", m.To, m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(l.Dir, 0o755); err != nil {
+		return err
+	}
+	recipient := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, m.To)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000"), recipient)
+	path := filepath.Join(l.Dir, name)
+	if err := os.WriteFile(path, msg, 0o600); err != nil {
+		return err
+	}
+	log.Printf("Mail to %s written to %s", m.To, path)
+	return nil
+}
@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"ecoscan.com/config"
+)
+
+// Message is one mail ready to send, Text is required and HTML is sent next to it when set
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers mail to users
+type Mailer interface {
+	// Name identifies the mailer in logs, e.g. "smtp"
+	Name() string
+	Send(ctx context.Context, m Message) error
+}
+
+// FromConfig builds the mailer selected by MAIL_PROVIDER
+func FromConfig(cnf *config.Config) (Mailer, error) {
+	switch cnf.MailProvider {
+	case "smtp":
+		return NewSMTP(cnf.SMTP, cnf.MailFrom), nil
+	case "log":
+		return &Log{Dir: cnf.MailDir}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail provider %q", cnf.MailProvider)
+	}
+}
@@ -0,0 +1,101 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"ecoscan.com/config"
+)
+
+// SMTP sends mail through a relay. port 465 speaks TLS from the start, other
+// ports upgrade with STARTTLS when the server offers it, and auth is only
+// attempted over TLS
+type SMTP struct {
+	cnf  config.SMTPConfig
+	from string
+}
+
+func NewSMTP(cnf config.SMTPConfig, from string) *SMTP {
+	return &SMTP{cnf: cnf, from: from}
+}
+
+func (s *SMTP) Name() string {
+	return "smtp"
+}
+
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	from, err := netmail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("MAIL_FROM: %w", err)
+	}
+	to, err := netmail.ParseAddress(m.To)
+	if err != nil {
+		return fmt.Errorf("recipient: %w", err)
+	}
+	msg, err := compose(from.String(), to.String(), m)
+	if err != nil {
+		return err
+	}
+
+	timeout := s.cnf.Timeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(s.cnf.Host, strconv.Itoa(s.cnf.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	// net/smtp has no contexts, the deadline bounds the whole conversation
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	tlsConfig := &tls.Config{ServerName: s.cnf.Host}
+	if s.cnf.Port == 465 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, s.cnf.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.cnf.Username != "" {
+		// PlainAuth refuses to send the password over a connection without TLS
+		if err := c.Auth(smtp.PlainAuth("", s.cnf.Username, s.cnf.Password, s.cnf.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,104 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	"ecoscan.com/i18n"
+)
+
+/* mail is written from templates/<lang>/<name>.txt and .html. the text file
+defines the "subject" next to the body, the html file fills in the "content"
+of templates/layout.html */
+
+const (
+	VerifyEmail   = "verify_email"
+	ResetPassword = "reset_password"
+)
+
+var Templates = []string{VerifyEmail, ResetPassword}
+
+//go:embed templates
+var files embed.FS
+
+type entry struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+var templates = map[string]entry{}
+
+func init() {
+	layout := htmltemplate.Must(htmltemplate.ParseFS(files, "templates/layout.html"))
+	for _, lang := range i18n.Supported {
+		for _, name := range Templates {
+			key := lang + "/" + name
+			text, err := template.ParseFS(files, "templates/"+key+".txt")
+			if err != nil {
+				panic("mail: " + err.Error())
+			}
+			if text.Lookup("subject") == nil {
+				panic("mail: " + key + ".txt defines no subject")
+			}
+			html, err := htmltemplate.Must(layout.Clone()).ParseFS(files, "templates/"+key+".html")
+			if err != nil {
+				panic("mail: " + err.Error())
+			}
+			templates[key] = entry{text: text, html: html}
+		}
+	}
+}
+
+// Data is what a template can refer to, e.g. {{.Link}}
+type Data struct {
+	Lang string
+	Name string
+	// the page the mail asks the user to open, with its token
+	Link string
+	// how long Link works, e.g. "48 hours"
+	ValidFor string
+}
+
+// Render writes the mail name in lang to the user at to. unsupported languages
+// get the default language's templates
+func Render(lang, name, to string, data Data, validFor time.Duration) (Message, error) {
+	if !i18n.IsSupported(lang) {
+		lang = i18n.Default
+	}
+	e := templates[lang+"/"+name]
+	data.Lang = lang
+	data.ValidFor = duration(lang, validFor)
+
+	var subject, text, html bytes.Buffer
+	if err := e.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := e.text.Execute(&text, data); err != nil {
+		return Message{}, err
+	}
+	if err := e.html.ExecuteTemplate(&html, "layout.html", data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// duration spells out a link's lifetime in whole hours, or minutes below an hour
+func duration(lang string, d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return i18n.T(lang, "mail_one_hour")
+	case d > time.Hour:
+		return i18n.T(lang, "mail_hours", int(d.Hours()))
+	default:
+		return i18n.T(lang, "mail_minutes", int(d.Minutes()))
+	}
+}
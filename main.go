@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"ecoscan.com/cmd"
 	_ "github.com/lib/pq"
 )
@@ -13,6 +15,5 @@ DB_PASSWORD=1212
 DB_ENABLE_SSL_MODE=false */
 
 func main() {
-
-	cmd.Serve()
+	os.Exit(cmd.Execute(os.Args[1:]))
 }
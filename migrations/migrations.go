@@ -0,0 +1,284 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+/* schema changes are pairs of files under sql/, NNNN_name.up.sql and
+NNNN_name.down.sql, applied in version order and recorded in
+schema_migrations. every step runs in its own transaction while the runner
+holds a session advisory lock, so instances starting together apply each
+migration once and the others wait for it */
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey is the pg_advisory_lock key, "ecoscan" in ASCII
+const lockKey int64 = 0x65636f7363616e
+
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Up      string `json:"-"`
+	Down    string `json:"-"`
+	// sha256 of the up step, to notice files edited after they were applied
+	Checksum string `json:"checksum"`
+}
+
+// Status is a migration as the database sees it. Migrations the database
+// has applied but this build doesn't know have an empty Up and Down
+type Status struct {
+	Migration
+	AppliedAt *time.Time `json:"applied_at"`
+	// applied from a different up step than the embedded one
+	Modified bool `json:"modified"`
+}
+
+func (s Status) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+var all []Migration
+
+func init() {
+	entries, err := files.ReadDir("sql")
+	if err != nil {
+		panic("migrations: " + err.Error())
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			panic("migrations: " + name + " is not NNNN_name.up.sql or NNNN_name.down.sql")
+		}
+		num, title, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if err != nil || version <= 0 {
+			panic("migrations: " + name + " has no version number")
+		}
+		src, err := files.ReadFile(path.Join("sql", name))
+		if err != nil {
+			panic("migrations: " + err.Error())
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		} else if m.Name != title {
+			panic(fmt.Sprintf("migrations: version %d is used by both %s and %s", version, m.Name, title))
+		}
+		if direction == "up" {
+			m.Up = string(src)
+			sum := sha256.Sum256(src)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(src)
+		}
+	}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			panic(fmt.Sprintf("migrations: %04d_%s needs both an up and a down step", m.Version, m.Name))
+		}
+		all = append(all, *m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+}
+
+// All returns the embedded migrations, oldest first
+func All() []Migration {
+	return append([]Migration(nil), all...)
+}
+
+// Up applies every pending migration and returns the ones it applied
+func Up(ctx context.Context, db *sqlx.DB) ([]Migration, error) {
+	var done []Migration
+	err := locked(ctx, db, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			err := step(ctx, conn, m.Up, `
+				INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+			`, m.Version, m.Name, m.Checksum)
+			if err != nil {
+				return fmt.Errorf("applying %04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the newest steps applied migrations and returns them, newest first
+func Down(ctx context.Context, db *sqlx.DB, steps int) ([]Migration, error) {
+	var done []Migration
+	err := locked(ctx, db, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		for _, v := range versions {
+			m, ok := find(v)
+			if !ok {
+				// reverting it would need the down step of a newer build
+				return fmt.Errorf("migration %d (%s) is not known to this build", v, applied[v].Name)
+			}
+			err := step(ctx, conn, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+			if err != nil {
+				return fmt.Errorf("reverting %04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Statuses lists the embedded migrations and any unknown applied ones, oldest first
+func Statuses(ctx context.Context, db *sqlx.DB) ([]Status, error) {
+	var statuses []Status
+	err := locked(ctx, db, func(conn *sqlx.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			s := Status{Migration: m}
+			if row, ok := applied[m.Version]; ok {
+				appliedAt := row.AppliedAt
+				s.AppliedAt = &appliedAt
+				s.Modified = row.Checksum != m.Checksum
+				delete(applied, m.Version)
+			}
+			statuses = append(statuses, s)
+		}
+		for _, row := range applied {
+			appliedAt := row.AppliedAt
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: row.Version, Name: row.Name, Checksum: row.Checksum},
+				AppliedAt: &appliedAt,
+			})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// Pending returns the embedded migrations the database hasn't applied yet
+func Pending(ctx context.Context, db *sqlx.DB) ([]Migration, error) {
+	statuses, err := Statuses(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range statuses {
+		if !s.Applied() {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+func find(version int) (Migration, bool) {
+	for _, m := range all {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// locked runs fn on one connection holding the advisory lock, session locks
+// belong to a connection so the pool can't be used directly
+func locked(ctx context.Context, db *sqlx.DB, fn func(conn *sqlx.Conn) error) error {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("waiting for the migration lock: %w", err)
+	}
+	// unlock even when ctx is done, the connection goes back to the pool
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+type appliedRow struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+func appliedVersions(ctx context.Context, conn *sqlx.Conn) (map[int]appliedRow, error) {
+	var rows []appliedRow
+	err := conn.SelectContext(ctx, &rows, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+	applied := make(map[int]appliedRow, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// step runs a migration's SQL and its schema_migrations bookkeeping in one transaction
+func step(ctx context.Context, conn *sqlx.Conn, sql, record string, args ...interface{}) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// no arguments, so lib/pq sends the whole file as one simple query
+	if _, err := tx.ExecContext(ctx, sql); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
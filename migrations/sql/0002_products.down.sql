@@ -0,0 +1,3 @@
+-- pg_trgm is left installed, other schemas in the database may use it
+DROP TABLE product_requests;
+DROP TABLE products;
@@ -0,0 +1,54 @@
+-- ProductStore.Search ranks names with similarity()
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- scores live in product_scores, products has no score column of its own
+CREATE TABLE IF NOT EXISTS products (
+    id SERIAL PRIMARY KEY,
+    barcode VARCHAR(14) NOT NULL UNIQUE CHECK (barcode ~ '^[0-9]{14}$'), -- canonical GTIN-14
+    name VARCHAR(255),
+    brand_name VARCHAR(255),
+    category VARCHAR(100),
+    sub_category VARCHAR(100),
+    image_url TEXT,
+    price NUMERIC(10,2),
+    packaging_material VARCHAR(100),
+    manufacturing_location VARCHAR(255),
+    disposal_method VARCHAR(100)
+);
+
+-- existing rows: EAN-8/EAN-13/UPC-A codes become GTIN-14 by left padding with zeros
+-- (UPC-E codes need expanding first, see barcode.ExpandUPCE)
+UPDATE products SET barcode = LPAD(barcode, 14, '0') WHERE length(barcode) < 14;
+
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx ON products USING gin (lower(name) gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS product_requests (
+    id BIGSERIAL PRIMARY KEY,
+    barcode VARCHAR(14) NOT NULL CHECK (barcode ~ '^[0-9]{14}$'), -- canonical GTIN-14
+    name VARCHAR(255) NOT NULL,
+    brand_name VARCHAR(255),
+    image_url TEXT,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- db_queries/product.sql defined product_requests twice, so only the first
+-- (older) definition could ever have been created. bring that one up to date
+UPDATE product_requests SET barcode = LPAD(barcode, 14, '0') WHERE length(barcode) < 14;
+UPDATE product_requests SET status = 'pending' WHERE status IS NULL;
+ALTER TABLE product_requests
+    ALTER COLUMN id TYPE BIGINT,
+    ALTER COLUMN user_id TYPE BIGINT,
+    ALTER COLUMN barcode TYPE VARCHAR(14),
+    ALTER COLUMN status SET NOT NULL,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS product_requests_status_idx ON product_requests (status);
+CREATE INDEX IF NOT EXISTS product_requests_user_id_idx ON product_requests (user_id);
+
+INSERT INTO products
+(barcode, name, brand_name, category, sub_category, image_url, price, packaging_material, manufacturing_location, disposal_method)
+VALUES
+('01234567890128', 'Mineral Water 1L', 'FreshCo', 'Beverages', 'Water', 'http://example.com/image.jpg', 25.50, 'Plastic Bottle', 'Dhaka, Bangladesh', 'Recycle')
+ON CONFLICT (barcode) DO NOTHING;
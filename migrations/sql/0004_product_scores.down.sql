@@ -0,0 +1,2 @@
+DROP TABLE scoring_rules;
+DROP TABLE product_scores;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS product_scores (
+    product_id INT PRIMARY KEY REFERENCES products(id) ON DELETE CASCADE,
+    score INT NOT NULL,
+    packaging_score INT NOT NULL,
+    location_score INT NOT NULL,
+    disposal_score INT NOT NULL,
+    breakdown JSONB NOT NULL DEFAULT '[]', -- repo.ScoreBreakdown, one entry per sub-score
+    confidence NUMERIC(3,2) NOT NULL DEFAULT 0, -- weighted share of inputs that were known, 0..1
+    scoring_version VARCHAR(50) NOT NULL, -- scoring_rules.fingerprint that produced the row
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- older tables lack the breakdown, rows without one are marked stale so the
+-- rescore job fills it in
+ALTER TABLE product_scores
+    ADD COLUMN IF NOT EXISTS breakdown JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS confidence NUMERIC(3,2) NOT NULL DEFAULT 0;
+UPDATE product_scores SET scoring_version = '' WHERE breakdown = '[]';
+
+CREATE INDEX IF NOT EXISTS product_scores_scoring_version_idx ON product_scores (scoring_version);
+CREATE INDEX IF NOT EXISTS product_scores_confidence_idx ON product_scores (confidence);
+
+-- every rulebook the service has scored with, keyed by logic.Rulebook.Fingerprint
+CREATE TABLE IF NOT EXISTS scoring_rules (
+    fingerprint VARCHAR(50) PRIMARY KEY,
+    version VARCHAR(40) NOT NULL,
+    body JSONB NOT NULL,
+    loaded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
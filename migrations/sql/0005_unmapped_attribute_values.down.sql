@@ -0,0 +1 @@
+DROP TABLE unmapped_attribute_values;
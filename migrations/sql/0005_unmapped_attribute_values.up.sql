@@ -0,0 +1,8 @@
+-- attribute values seen while scoring that the taxonomy package has no code for
+CREATE TABLE IF NOT EXISTS unmapped_attribute_values (
+    kind VARCHAR(50) NOT NULL, -- packaging_material, manufacturing_location or disposal_method
+    value VARCHAR(255) NOT NULL,
+    first_seen TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (kind, value)
+);
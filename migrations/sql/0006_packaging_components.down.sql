@@ -0,0 +1,4 @@
+DROP TRIGGER products_invalidate_score ON products;
+DROP FUNCTION invalidate_product_score_on_update();
+DROP TABLE product_packaging_components;
+DROP FUNCTION invalidate_product_score();
@@ -0,0 +1,38 @@
+-- a product's packaging split into parts, e.g. bottle, cap, label and shrink wrap.
+-- products without rows here are scored from products.packaging_material alone
+CREATE TABLE IF NOT EXISTS product_packaging_components (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    component VARCHAR(50) NOT NULL,
+    material VARCHAR(100) NOT NULL,
+    mass_grams NUMERIC(10,2) NOT NULL DEFAULT 0 CHECK (mass_grams >= 0),
+    recyclable BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS product_packaging_components_product_id_idx ON product_packaging_components (product_id);
+
+-- drop the stored score whenever what it was computed from changes, the next
+-- lookup (or the rescore job) computes a fresh one
+CREATE OR REPLACE FUNCTION invalidate_product_score() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM product_scores WHERE product_id = COALESCE(NEW.product_id, OLD.product_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS packaging_components_invalidate_score ON product_packaging_components;
+CREATE TRIGGER packaging_components_invalidate_score
+AFTER INSERT OR UPDATE OR DELETE ON product_packaging_components
+FOR EACH ROW EXECUTE FUNCTION invalidate_product_score();
+
+CREATE OR REPLACE FUNCTION invalidate_product_score_on_update() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM product_scores WHERE product_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_invalidate_score ON products;
+CREATE TRIGGER products_invalidate_score
+AFTER UPDATE OF packaging_material, manufacturing_location, disposal_method ON products
+FOR EACH ROW EXECUTE FUNCTION invalidate_product_score_on_update();
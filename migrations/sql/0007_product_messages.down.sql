@@ -0,0 +1,4 @@
+DROP TABLE product_messages;
+DROP TRIGGER products_invalidate_messages ON products;
+DROP FUNCTION invalidate_product_messages();
+DROP TABLE product_message_cache;
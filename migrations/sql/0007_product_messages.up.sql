@@ -0,0 +1,57 @@
+-- generated motivational messages, see llm.MessageCache
+CREATE TABLE IF NOT EXISTS product_message_cache (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    score_bucket INT NOT NULL, -- score / 10
+    lang VARCHAR(10) NOT NULL,
+    prompt_version VARCHAR(40) NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    message TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (product_id, score_bucket, lang, prompt_version)
+);
+
+CREATE INDEX IF NOT EXISTS product_message_cache_expires_at_idx ON product_message_cache (expires_at);
+
+-- a renamed or re-described product shouldn't keep its old message
+CREATE OR REPLACE FUNCTION invalidate_product_messages() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM product_message_cache WHERE product_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_invalidate_messages ON products;
+CREATE TRIGGER products_invalidate_messages
+AFTER UPDATE ON products
+FOR EACH ROW EXECUTE FUNCTION invalidate_product_messages();
+
+-- curated per product messages, served instead of generated ones while active
+CREATE TABLE IF NOT EXISTS product_messages (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    lang VARCHAR(10) NOT NULL,
+    message TEXT NOT NULL,
+    priority INT NOT NULL DEFAULT 0, -- highest active one wins
+    starts_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ends_at TIMESTAMPTZ, -- NULL means open ended
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS product_messages_product_id_lang_idx ON product_messages (product_id, lang);
+
+-- the messages that used to be hard-coded in GetProduct. 894110001003 had two
+-- blocks and the second (Coca-Cola) always won; the paper towel text below it
+-- was never served and has no known barcode, so it is left out
+INSERT INTO product_messages (product_id, lang, message, priority)
+SELECT p.id, 'bn', m.message, 100
+FROM (VALUES
+    ('00894110001003', 'Coca-Cola যেকোনো মুহূর্তকে আর রেফ্রেশিং করে তুলে🌱 এই প্যাকেজিংটা প্লাস্টিক হলেও তুলনামূলকভাবে পরিবেশবান্ধব। আপনি নিচে আমাদের Alternatives পণ্যগুলো দেখতে পারেন। পরিবেশ রক্ষায় এভাব আপনার অবদান রাখুন। 🌱।'),
+    ('00894110001473', 'Pepsi প্রতিটি moment-কে করে তোলে আরও lively আর energetic ✨ ক্যান প্যাকেজিং হওয়ায় এটি easily recyclable এবং eco-friendly। আপনার এই conscious choice পরিবেশ রক্ষায় একটি গুরুত্বপূর্ণ step 🌍। আমরা আপনার decision-কে সত্যিই appreciate করি🌱।'),
+    ('00894110001004', 'Clemon Lemon Soda প্রতিটি sip-কে করে তোলে আরও refreshing 🍋✨ 250ml Can প্যাকেজিং হওয়ায় এটি super easy to recycle এবং eco-friendly choice। আপনার এই cool decision পরিবেশ রক্ষায় একটি ছোট কিন্তু impactful step 🌍। আমরা আপনার conscious lifestyle-কে সত্যিই appreciate করি🌱।')
+) AS m (barcode, message)
+JOIN products p ON p.barcode = m.barcode
+WHERE NOT EXISTS (SELECT 1 FROM product_messages pm WHERE pm.product_id = p.id);
@@ -0,0 +1,12 @@
+-- per day usage of every message provider and model, see llm.UsageStore.
+-- the daily budgets are checked against these counters
+CREATE TABLE IF NOT EXISTS llm_usage (
+    day DATE NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    requests INT NOT NULL DEFAULT 0, -- every attempt, retries included
+    failures INT NOT NULL DEFAULT 0,
+    prompt_tokens BIGINT NOT NULL DEFAULT 0,
+    completion_tokens BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, provider, model)
+);
@@ -0,0 +1,10 @@
+-- hashes can't be turned back into tokens, everyone has to log in again
+DELETE FROM refresh_tokens;
+
+DROP INDEX refresh_tokens_family_idx;
+ALTER TABLE refresh_tokens
+    DROP COLUMN family,
+    DROP COLUMN created_at,
+    DROP COLUMN used_at,
+    DROP COLUMN revoked_at;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
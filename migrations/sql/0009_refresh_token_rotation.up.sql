@@ -0,0 +1,17 @@
+-- refresh tokens are single use and stored as sha256 hex digests, see
+-- utils.HashRefreshToken. every token exchanged from one login shares a family,
+-- presenting a used token revokes the whole family
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+UPDATE refresh_tokens SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+ALTER TABLE refresh_tokens
+    ADD COLUMN family VARCHAR(32),
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ADD COLUMN used_at TIMESTAMPTZ, -- exchanged for a new pair, a second use is a reuse
+    ADD COLUMN revoked_at TIMESTAMPTZ;
+
+-- tokens from before rotation each start their own family
+UPDATE refresh_tokens SET family = md5(id::text || random()::text);
+ALTER TABLE refresh_tokens ALTER COLUMN family SET NOT NULL;
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family);
@@ -0,0 +1,2 @@
+ALTER TABLE refresh_tokens DROP CONSTRAINT refresh_tokens_family_fkey;
+DROP TABLE sessions;
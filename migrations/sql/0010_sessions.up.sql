@@ -0,0 +1,24 @@
+-- a session is one login on one device. its refresh tokens are one family and
+-- its access tokens carry the id as their sid claim
+CREATE TABLE sessions (
+    id VARCHAR(32) PRIMARY KEY, -- refresh_tokens.family
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL, -- that of its newest refresh token
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- families from before sessions become sessions without device details
+INSERT INTO sessions (id, user_id, created_at, last_used_at, expires_at, revoked_at)
+SELECT family, user_id, MIN(created_at), MAX(created_at), MAX(expires_at),
+    CASE WHEN bool_and(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family, user_id;
+
+ALTER TABLE refresh_tokens ADD CONSTRAINT refresh_tokens_family_fkey
+    FOREIGN KEY (family) REFERENCES sessions(id) ON DELETE CASCADE;
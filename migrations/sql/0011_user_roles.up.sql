@@ -0,0 +1,4 @@
+-- what a user may do, see repo.Role. every existing account is a plain user,
+-- admins are made with `ecoscan user create-admin` or `ecoscan user set-role`
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
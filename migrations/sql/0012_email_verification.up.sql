@@ -0,0 +1,3 @@
+-- set when the user opens the link mailed to their address, cleared again
+-- when they change it. accounts from before this are unverified
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
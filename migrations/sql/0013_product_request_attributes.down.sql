@@ -0,0 +1,4 @@
+ALTER TABLE product_requests
+    DROP COLUMN packaging_material,
+    DROP COLUMN manufacturing_location,
+    DROP COLUMN disposal_method;
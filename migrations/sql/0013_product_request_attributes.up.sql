@@ -0,0 +1,6 @@
+-- what the requester could tell about the packaging, stored as taxonomy codes
+-- when they map, as typed when they don't
+ALTER TABLE product_requests
+    ADD COLUMN packaging_material VARCHAR(100),
+    ADD COLUMN manufacturing_location VARCHAR(255),
+    ADD COLUMN disposal_method VARCHAR(100);
@@ -0,0 +1,2 @@
+-- the addresses stay lower case, there is no telling how they were typed
+ALTER TABLE users DROP CONSTRAINT users_email_normalized_check;
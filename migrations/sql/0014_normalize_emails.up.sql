@@ -0,0 +1,19 @@
+-- emails are stored as repo.NormalizeEmail leaves them, trimmed and lower
+-- case. two accounts whose addresses only differ in case would become one
+-- address, so the migration stops and lists them to merge by hand
+DO $$
+DECLARE
+    bad TEXT;
+BEGIN
+    SELECT string_agg(emails, '; ') INTO bad FROM (
+        SELECT string_agg(format('%s (id %s)', email, id), ', ' ORDER BY id) AS emails
+        FROM users GROUP BY lower(btrim(email)) HAVING count(*) > 1
+    ) AS duplicates;
+    IF bad IS NOT NULL THEN
+        RAISE EXCEPTION 'users whose emails only differ in case, merge them and migrate again: %', bad;
+    END IF;
+END $$;
+
+UPDATE users SET email = lower(btrim(email)) WHERE email <> lower(btrim(email));
+
+ALTER TABLE users ADD CONSTRAINT users_email_normalized_check CHECK (email = lower(btrim(email)));
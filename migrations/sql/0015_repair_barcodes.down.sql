@@ -0,0 +1,14 @@
+-- repaired barcodes stay repaired, they are what lookups find. the requests
+-- in product_requests_invalid are kept, they may not fit product_requests
+ALTER TABLE product_requests
+    DROP CONSTRAINT product_requests_barcode_check,
+    ADD CONSTRAINT product_requests_barcode_check CHECK (barcode ~ '^[0-9]{14}$');
+ALTER TABLE products
+    DROP CONSTRAINT products_barcode_check,
+    ADD CONSTRAINT products_barcode_check CHECK (barcode ~ '^[0-9]{14}$');
+
+DROP FUNCTION gtin14_repair(TEXT);
+DROP FUNCTION gtin14(TEXT);
+DROP FUNCTION upce_to_upca(TEXT);
+DROP FUNCTION gtin_check_ok(TEXT);
+DROP FUNCTION gtin_check_digit(TEXT);
@@ -0,0 +1,109 @@
+-- 0002 only left padded stored codes to 14 digits. a UPC-E code became an
+-- EAN-8 one that doesn't exist and an EAN-13 code saved without its check
+-- digit kept the wrong one, so neither could be scanned. this repairs them
+-- and makes the check digit part of the barcode constraints
+
+-- the barcode package in SQL. gtin_check_digit is barcode.CheckDigit
+CREATE OR REPLACE FUNCTION gtin_check_digit(payload TEXT) RETURNS INT AS $$
+    SELECT (10 - COALESCE(SUM(substr(payload, i, 1)::INT * CASE WHEN (length(payload) - i) % 2 = 0 THEN 3 ELSE 1 END), 0) % 10) % 10
+    FROM generate_series(1, length(payload)) AS i
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION gtin_check_ok(code TEXT) RETURNS BOOLEAN AS $$
+    SELECT CASE WHEN code ~ '^[0-9]{2,}$' THEN gtin_check_digit(left(code, -1)) = right(code, 1)::INT ELSE FALSE END
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- barcode.ExpandUPCE, NULL when upce is no valid UPC-E code
+CREATE OR REPLACE FUNCTION upce_to_upca(upce TEXT) RETURNS TEXT AS $$
+    SELECT CASE WHEN gtin_check_ok(upca) THEN upca END
+    FROM (SELECT CASE WHEN upce !~ '^[01][0-9]{7}$' THEN NULL
+        ELSE left(upce, 1) || CASE
+            WHEN substr(upce, 7, 1) IN ('0', '1', '2') THEN substr(upce, 2, 2) || substr(upce, 7, 1) || '0000' || substr(upce, 4, 3)
+            WHEN substr(upce, 7, 1) = '3' THEN substr(upce, 2, 3) || '00000' || substr(upce, 5, 2)
+            WHEN substr(upce, 7, 1) = '4' THEN substr(upce, 2, 4) || '00000' || substr(upce, 6, 1)
+            ELSE substr(upce, 2, 5) || '0000' || substr(upce, 7, 1)
+        END || right(upce, 1)
+    END AS upca) AS expanded
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- barcode.Normalize, NULL for what it rejects. 8 digits read as EAN-8 when
+-- the check digit allows it and as UPC-E otherwise, like barcode.Parse
+CREATE OR REPLACE FUNCTION gtin14(raw TEXT) RETURNS TEXT AS $$
+    SELECT CASE
+        WHEN length(code) = 8 AND gtin_check_ok(code) THEN lpad(code, 14, '0')
+        WHEN length(code) = 8 THEN lpad(upce_to_upca(code), 14, '0')
+        WHEN length(code) IN (12, 13, 14) AND gtin_check_ok(code) THEN lpad(code, 14, '0')
+    END
+    FROM (SELECT regexp_replace(trim(raw), '[ -]', '', 'g') AS code) AS cleaned
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- what a stored code becomes. on top of gtin14 it undoes the padding of 0002:
+-- UPC-E 04252614 stored as 00000004252614 is 00042100005264, and 894110001003,
+-- an EAN-13 code missing its check digit stored as 00894110001003, is 08941100010037
+CREATE OR REPLACE FUNCTION gtin14_repair(raw TEXT) RETURNS TEXT AS $$
+    SELECT COALESCE(gtin14(code), CASE
+        WHEN code ~ '^0{6}[01][0-9]{7}$' THEN gtin14(right(code, 8))
+        WHEN code ~ '^0{0,2}[1-9][0-9]{11}$' THEN '0' || right(code, 12) || gtin_check_digit(right(code, 12))
+    END)
+    FROM (SELECT regexp_replace(trim(raw), '[ -]', '', 'g') AS code) AS cleaned
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- db_queries/product.sql seeded this product as 1234567890123, which has the
+-- wrong check digit. 0002 padded it and seeded it again as 01234567890128
+DELETE FROM products
+WHERE barcode IN ('1234567890123', '01234567890123') AND name = 'Mineral Water 1L' AND brand_name = 'FreshCo'
+    AND EXISTS (SELECT 1 FROM products WHERE barcode = '01234567890128');
+UPDATE products SET barcode = '01234567890128'
+WHERE barcode IN ('1234567890123', '01234567890123') AND name = 'Mineral Water 1L' AND brand_name = 'FreshCo';
+
+-- a product whose code can't be repaired, or that would end up with another
+-- product's code, would never be found again, so the migration stops and
+-- lists them to fix by hand
+DO $$
+DECLARE
+    bad TEXT;
+BEGIN
+    SELECT string_agg(format('%s (id %s)', barcode, id), ', ' ORDER BY id) INTO bad
+    FROM products WHERE gtin14_repair(barcode) IS NULL;
+    IF bad IS NOT NULL THEN
+        RAISE EXCEPTION 'products with invalid barcodes, correct or delete them and migrate again: %', bad;
+    END IF;
+
+    SELECT string_agg(codes, '; ') INTO bad FROM (
+        SELECT string_agg(format('%s (id %s)', barcode, id), ', ' ORDER BY id) AS codes
+        FROM products GROUP BY gtin14_repair(barcode) HAVING count(*) > 1
+    ) AS duplicates;
+    IF bad IS NOT NULL THEN
+        RAISE EXCEPTION 'products whose barcodes are the same GTIN, merge them and migrate again: %', bad;
+    END IF;
+END $$;
+
+UPDATE products SET barcode = gtin14_repair(barcode) WHERE barcode <> gtin14_repair(barcode);
+
+-- a table adopted from db_queries never got the CHECK of 0002's CREATE TABLE
+ALTER TABLE products
+    ALTER COLUMN barcode TYPE VARCHAR(14),
+    DROP CONSTRAINT IF EXISTS products_barcode_check,
+    ADD CONSTRAINT products_barcode_check CHECK (barcode ~ '^[0-9]{14}$' AND gtin_check_ok(barcode));
+
+-- an adopted product_requests took any barcode and no name. requests that
+-- can't be brought into shape are moved to product_requests_invalid for
+-- someone to look at
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM product_requests WHERE gtin14_repair(barcode) IS NULL OR name IS NULL) THEN
+        CREATE TABLE IF NOT EXISTS product_requests_invalid AS
+            SELECT * FROM product_requests WITH NO DATA;
+        INSERT INTO product_requests_invalid
+            SELECT * FROM product_requests WHERE gtin14_repair(barcode) IS NULL OR name IS NULL;
+        DELETE FROM product_requests WHERE gtin14_repair(barcode) IS NULL OR name IS NULL;
+        RAISE NOTICE 'moved product requests with an invalid barcode or no name to product_requests_invalid';
+    END IF;
+END $$;
+
+UPDATE product_requests SET barcode = gtin14_repair(barcode) WHERE barcode <> gtin14_repair(barcode);
+ALTER TABLE product_requests
+    ALTER COLUMN name SET NOT NULL,
+    ALTER COLUMN status TYPE VARCHAR(50),
+    DROP CONSTRAINT IF EXISTS product_requests_barcode_check,
+    ADD CONSTRAINT product_requests_barcode_check CHECK (barcode ~ '^[0-9]{14}$' AND gtin_check_ok(barcode));
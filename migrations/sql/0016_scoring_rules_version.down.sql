@@ -0,0 +1,2 @@
+-- fails while a rulebook with a 41 character version is recorded
+ALTER TABLE scoring_rules ALTER COLUMN version TYPE VARCHAR(40);
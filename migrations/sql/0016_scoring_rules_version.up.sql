@@ -0,0 +1,3 @@
+-- logic.MaxVersionLength, the longest version whose fingerprint still fits
+-- product_scores.scoring_version
+ALTER TABLE scoring_rules ALTER COLUMN version TYPE VARCHAR(41);
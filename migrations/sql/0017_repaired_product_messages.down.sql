@@ -0,0 +1,2 @@
+-- nothing to undo. the messages are left, 0007 may have seeded the same ones
+-- and they can be edited or deleted like any other override
@@ -0,0 +1,11 @@
+-- 0007 seeded the Coca-Cola and Clemon messages under 00894110001003 and
+-- 00894110001004, codes missing their check digit. products stored under the
+-- right codes, repaired by 0015 or imported since, get them here
+INSERT INTO product_messages (product_id, lang, message, priority)
+SELECT p.id, 'bn', m.message, 100
+FROM (VALUES
+    ('08941100010037', 'Coca-Cola যেকোনো মুহূর্তকে আর রেফ্রেশিং করে তুলে🌱 এই প্যাকেজিংটা প্লাস্টিক হলেও তুলনামূলকভাবে পরিবেশবান্ধব। আপনি নিচে আমাদের Alternatives পণ্যগুলো দেখতে পারেন। পরিবেশ রক্ষায় এভাব আপনার অবদান রাখুন। 🌱।'),
+    ('08941100010044', 'Clemon Lemon Soda প্রতিটি sip-কে করে তোলে আরও refreshing 🍋✨ 250ml Can প্যাকেজিং হওয়ায় এটি super easy to recycle এবং eco-friendly choice। আপনার এই cool decision পরিবেশ রক্ষায় একটি ছোট কিন্তু impactful step 🌍। আমরা আপনার conscious lifestyle-কে সত্যিই appreciate করি🌱।')
+) AS m (barcode, message)
+JOIN products p ON p.barcode = m.barcode
+WHERE NOT EXISTS (SELECT 1 FROM product_messages pm WHERE pm.product_id = p.id);
@@ -0,0 +1,115 @@
+package prompt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"ecoscan.com/i18n"
+	"ecoscan.com/repo"
+)
+
+/* message prompts are text/template files under templates/<lang>/<band>.tmpl.
+a template's version is its name plus a short content hash, so editing a file
+is enough to stop serving messages generated from the old wording */
+
+const (
+	Low  = "low"
+	Good = "good"
+)
+
+var Bands = []string{Low, Good}
+
+// LowScoreBelow is where the "could save" prompt hands over to the "saved" one
+const LowScoreBelow = 60
+
+//go:embed templates
+var files embed.FS
+
+type entry struct {
+	tmpl    *template.Template
+	version string
+}
+
+var templates = map[string]entry{}
+
+func init() {
+	for _, lang := range i18n.Supported {
+		for _, band := range Bands {
+			name := lang + "/" + band
+			src, err := files.ReadFile("templates/" + name + ".tmpl")
+			if err != nil {
+				panic("prompt: missing template " + name)
+			}
+			tmpl, err := template.New(name).Option("missingkey=error").Parse(string(src))
+			if err != nil {
+				panic("prompt: parsing " + name + ": " + err.Error())
+			}
+			sum := sha256.Sum256(src)
+			templates[name] = entry{tmpl: tmpl, version: name + "@" + hex.EncodeToString(sum[:])[:8]}
+		}
+	}
+}
+
+// Data is what a template can refer to, e.g. {{.Name}}
+type Data struct {
+	Name        string
+	Brand       string
+	Category    string
+	SubCategory string
+	Packaging   string
+	Location    string
+	Disposal    string
+	Score       int
+}
+
+// Prompt is a rendered template and the version it was rendered from
+type Prompt struct {
+	Text    string
+	Version string
+}
+
+// Band picks the template band for a score
+func Band(score int) string {
+	if score < LowScoreBelow {
+		return Low
+	}
+	return Good
+}
+
+// Version identifies the template Render would use, without rendering it
+func Version(lang string, score int) string {
+	return lookup(lang, score).version
+}
+
+// Render fills in the template for the language and the score's band.
+// unsupported languages get the default language's templates.
+func Render(lang string, p repo.Product, score int) (Prompt, error) {
+	e := lookup(lang, score)
+	data := Data{
+		Name:        p.Name,
+		Brand:       p.BrandName,
+		Category:    p.Category,
+		SubCategory: p.SubCatergory,
+		Packaging:   p.PackagingMaterial,
+		Location:    p.ManufacturingLocation,
+		Disposal:    p.DisposalMethod,
+		Score:       score,
+	}
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, data); err != nil {
+		return Prompt{}, fmt.Errorf("rendering prompt %s: %w", e.version, err)
+	}
+	return Prompt{Text: strings.TrimSpace(buf.String()), Version: e.version}, nil
+}
+
+func lookup(lang string, score int) entry {
+	if !i18n.IsSupported(lang) {
+		lang = i18n.Default
+	}
+	return templates[lang+"/"+Band(score)]
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"ecoscan.com/repo"
+)
+
+/* in-memory stores for tests and for running the API without Postgres. every
+store made from one Data shares its state, like tables in one database, so a
+product request credits points to a user in the UserStore. */
+
+type Data struct {
+	mu sync.RWMutex
+
+	products map[int]repo.Product
+	scores   map[int]repo.Score
+	unmapped []repo.UnmappedAttribute
+	messages map[int64]repo.ProductMessage
+	users    map[int64]repo.User
+	requests []repo.ProductRequest
+	tokens   []repo.RefreshToken
+	sessions map[string]repo.Session
+
+	nextID int64
+}
+
+func New() *Data {
+	return &Data{
+		products: map[int]repo.Product{},
+		scores:   map[int]repo.Score{},
+		messages: map[int64]repo.ProductMessage{},
+		users:    map[int64]repo.User{},
+		sessions: map[string]repo.Session{},
+	}
+}
+
+func (d *Data) Products() *ProductStore { return &ProductStore{d} }
+func (d *Data) Users() *UserStore       { return &UserStore{d} }
+func (d *Data) Requests() *RequestStore { return &RequestStore{d} }
+func (d *Data) Tokens() *TokenStore     { return &TokenStore{d} }
+func (d *Data) Scores() *ScoreStore     { return &ScoreStore{d} }
+
+// AddProduct stores p, assigning an id when it has none
+func (d *Data) AddProduct(p repo.Product) repo.Product {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if p.ID == 0 {
+		p.ID = int(d.id())
+	}
+	d.products[p.ID] = p
+	return p
+}
+
+// SetScore stores a computed score, the equivalent of a product_scores row
+func (d *Data) SetScore(s repo.Score) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.scores[s.ProductID] = s
+}
+
+// ReportUnmapped records an attribute value the vocabulary didn't know
+func (d *Data) ReportUnmapped(kind, value string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for i, u := range d.unmapped {
+		if u.Kind == kind && u.Value == value {
+			d.unmapped[i].LastSeen = now
+			return
+		}
+	}
+	d.unmapped = append(d.unmapped, repo.UnmappedAttribute{Kind: kind, Value: value, FirstSeen: now, LastSeen: now})
+}
+
+// id hands out ids shared by every table, callers hold the lock
+func (d *Data) id() int64 {
+	d.nextID++
+	return d.nextID
+}
+
+var (
+	_ repo.ProductStore = (*ProductStore)(nil)
+	_ repo.UserStore    = (*UserStore)(nil)
+	_ repo.RequestStore = (*RequestStore)(nil)
+	_ repo.TokenStore   = (*TokenStore)(nil)
+)
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"ecoscan.com/repo"
+)
+
+func (s *ProductStore) CuratedMessage(_ context.Context, productID int, lang string) (string, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	now := time.Now()
+	var best *repo.ProductMessage
+	for _, m := range s.d.messages {
+		if m.ProductID != productID || m.Lang != lang || m.StartsAt.After(now) || (m.EndsAt != nil && !m.EndsAt.After(now)) {
+			continue
+		}
+		if best == nil || m.Priority > best.Priority || (m.Priority == best.Priority && m.UpdatedAt.After(best.UpdatedAt)) {
+			best = &m
+		}
+	}
+	if best == nil {
+		return "", repo.ErrNotFound
+	}
+	return best.Message, nil
+}
+
+func (s *ProductStore) ListMessages(_ context.Context, filter repo.MessageFilter) ([]repo.ProductMessage, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	messages := []repo.ProductMessage{}
+	for _, m := range s.d.messages {
+		m.Barcode = s.d.products[m.ProductID].Barcode
+		if (filter.ProductID == 0 || m.ProductID == filter.ProductID) && (filter.Lang == "" || m.Lang == filter.Lang) {
+			messages = append(messages, m)
+		}
+	}
+	slices.SortFunc(messages, func(a, b repo.ProductMessage) int {
+		return cmp.Or(cmp.Compare(a.Barcode, b.Barcode), cmp.Compare(a.Lang, b.Lang), cmp.Compare(b.Priority, a.Priority), cmp.Compare(a.ID, b.ID))
+	})
+	return messages, nil
+}
+
+func (s *ProductStore) GetMessage(_ context.Context, id int64) (repo.ProductMessage, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	m, ok := s.d.messages[id]
+	if !ok {
+		return repo.ProductMessage{}, repo.ErrNotFound
+	}
+	m.Barcode = s.d.products[m.ProductID].Barcode
+	return m, nil
+}
+
+func (s *ProductStore) CreateMessage(_ context.Context, m repo.ProductMessage) (int64, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	now := time.Now()
+	if m.StartsAt.IsZero() {
+		m.StartsAt = now
+	}
+	if err := checkWindow(m); err != nil {
+		return 0, err
+	}
+	m.ID = s.d.id()
+	m.CreatedAt, m.UpdatedAt = now, now
+	s.d.messages[m.ID] = m
+	return m.ID, nil
+}
+
+func (s *ProductStore) UpdateMessage(_ context.Context, m repo.ProductMessage) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	stored, ok := s.d.messages[m.ID]
+	if !ok {
+		return repo.ErrNotFound
+	}
+	if m.StartsAt.IsZero() {
+		m.StartsAt = stored.StartsAt
+	}
+	if err := checkWindow(m); err != nil {
+		return err
+	}
+	m.CreatedBy, m.CreatedAt, m.UpdatedAt = stored.CreatedBy, stored.CreatedAt, time.Now()
+	s.d.messages[m.ID] = m
+	return nil
+}
+
+func (s *ProductStore) DeleteMessage(_ context.Context, id int64) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if _, ok := s.d.messages[id]; !ok {
+		return repo.ErrNotFound
+	}
+	delete(s.d.messages, id)
+	return nil
+}
+
+// checkWindow is the product_messages CHECK constraint
+func checkWindow(m repo.ProductMessage) error {
+	if m.EndsAt != nil && !m.EndsAt.After(m.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", repo.ErrInvalid)
+	}
+	return nil
+}
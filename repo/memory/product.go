@@ -0,0 +1,210 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"strings"
+
+	"ecoscan.com/repo"
+)
+
+type ProductStore struct {
+	d *Data
+}
+
+// greener packaging that qualifies as an alternative regardless of price
+var greenPackaging = []string{"glass", "paper", "none", "compostable_paper", "cardboard"}
+
+func (s *ProductStore) ByBarcode(_ context.Context, code string) (repo.Product, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	for _, p := range s.d.products {
+		if p.Barcode == code {
+			return p, nil
+		}
+	}
+	return repo.Product{}, repo.ErrNotFound
+}
+
+func (s *ProductStore) Upsert(_ context.Context, p repo.Product) (bool, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	p.Score = 0
+	for id, existing := range s.d.products {
+		if existing.Barcode == p.Barcode {
+			p.ID = id
+			keep(&p.Name, existing.Name)
+			keep(&p.BrandName, existing.BrandName)
+			keep(&p.Category, existing.Category)
+			keep(&p.SubCatergory, existing.SubCatergory)
+			keep(&p.ImageURL, existing.ImageURL)
+			keep(&p.PackagingMaterial, existing.PackagingMaterial)
+			keep(&p.ManufacturingLocation, existing.ManufacturingLocation)
+			keep(&p.DisposalMethod, existing.DisposalMethod)
+			if p.Price == 0 {
+				p.Price = existing.Price
+			}
+			s.d.products[id] = p
+			// like the trigger on products, a changed product needs a new score
+			delete(s.d.scores, id)
+			return false, nil
+		}
+	}
+	p.ID = int(s.d.id())
+	s.d.products[p.ID] = p
+	return true, nil
+}
+
+// keep leaves a stored value in place when the update has none
+func keep(field *string, stored string) {
+	if *field == "" {
+		*field = stored
+	}
+}
+
+func (s *ProductStore) Alternatives(_ context.Context, p repo.Product, limit int) ([]repo.Product, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	var alternatives []repo.Product
+	for _, other := range s.d.products {
+		if other.SubCatergory != p.SubCatergory || other.ID == p.ID {
+			continue
+		}
+		if other.Price < p.Price || slices.Contains(greenPackaging, other.PackagingMaterial) {
+			alternatives = append(alternatives, other)
+		}
+	}
+	slices.SortFunc(alternatives, func(a, b repo.Product) int {
+		return cmp.Or(cmp.Compare(b.Price, a.Price), cmp.Compare(a.PackagingMaterial, b.PackagingMaterial), cmp.Compare(a.ID, b.ID))
+	})
+	return alternatives[:min(limit, len(alternatives))], nil
+}
+
+// Search stands in for pg_trgm with a case-insensitive substring match
+func (s *ProductStore) Search(_ context.Context, query string, limit int) ([]repo.Product, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	query = strings.ToLower(query)
+	var results []repo.Product
+	for _, p := range s.d.products {
+		if strings.Contains(strings.ToLower(p.Name), query) {
+			results = append(results, p)
+		}
+	}
+	exact := func(p repo.Product) int {
+		if strings.ToLower(p.Name) == query {
+			return 0
+		}
+		return 1
+	}
+	slices.SortFunc(results, func(a, b repo.Product) int {
+		return cmp.Or(cmp.Compare(exact(a), exact(b)), cmp.Compare(len(a.Name), len(b.Name)), cmp.Compare(a.ID, b.ID))
+	})
+	return results[:min(limit, len(results))], nil
+}
+
+func (s *ProductStore) CategoryRank(_ context.Context, productID int, category string) (repo.CategoryRank, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	p, ok := s.d.products[productID]
+	own, scored := s.d.scores[productID]
+	if !ok || !scored || p.Category != category {
+		return repo.CategoryRank{}, repo.ErrNotFound
+	}
+
+	var inCategory, inSub, atOrBelowCategory, atOrBelowSub, above int
+	best := own.Score
+	for id, other := range s.d.products {
+		score, ok := s.d.scores[id]
+		if !ok || other.Category != category {
+			continue
+		}
+		inCategory++
+		if score.Score <= own.Score {
+			atOrBelowCategory++
+		}
+		if other.SubCatergory != p.SubCatergory {
+			continue
+		}
+		inSub++
+		if score.Score <= own.Score {
+			atOrBelowSub++
+		}
+		if score.Score > own.Score {
+			above++
+		}
+		best = max(best, score.Score)
+	}
+
+	return repo.CategoryRank{
+		Category:           p.Category,
+		SubCategory:        p.SubCatergory,
+		Percentile:         math.Round(float64(atOrBelowSub) / float64(inSub) * 100),
+		CategoryPercentile: math.Round(float64(atOrBelowCategory) / float64(inCategory) * 100),
+		Rank:               above + 1,
+		Peers:              inSub,
+		BestInCategory:     own.Score == best && inSub > 1,
+	}, nil
+}
+
+func (s *ProductStore) LowConfidence(_ context.Context, limit int) ([]repo.ScoredProduct, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	var rows []repo.ScoredProduct
+	for id, score := range s.d.scores {
+		p, ok := s.d.products[id]
+		if !ok {
+			continue
+		}
+		p.Score = score.Score
+		rows = append(rows, repo.ScoredProduct{Product: p, Confidence: score.Confidence, Breakdown: score.Breakdown})
+	}
+	slices.SortFunc(rows, func(a, b repo.ScoredProduct) int {
+		return cmp.Or(cmp.Compare(a.Confidence, b.Confidence), cmp.Compare(a.Score, b.Score), cmp.Compare(a.ID, b.ID))
+	})
+	return rows[:min(limit, len(rows))], nil
+}
+
+func (s *ProductStore) UnmappedAttributes(_ context.Context) ([]repo.UnmappedAttribute, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	rows := make([]repo.UnmappedAttribute, 0, len(s.d.unmapped))
+	for _, u := range s.d.unmapped {
+		for _, p := range s.d.products {
+			if usesValue(p, u.Kind, u.Value) {
+				u.ProductCount++
+			}
+		}
+		rows = append(rows, u)
+	}
+	slices.SortFunc(rows, func(a, b repo.UnmappedAttribute) int {
+		return cmp.Or(cmp.Compare(b.ProductCount, a.ProductCount), b.LastSeen.Compare(a.LastSeen))
+	})
+	return rows, nil
+}
+
+func (s *ProductStore) ReportUnmapped(_ context.Context, kind, value string) error {
+	s.d.ReportUnmapped(kind, value)
+	return nil
+}
+
+func usesValue(p repo.Product, kind, value string) bool {
+	switch kind {
+	case "packaging_material":
+		if p.PackagingMaterial == value {
+			return true
+		}
+		for _, c := range p.PackagingComponents {
+			if c.Material == value {
+				return true
+			}
+		}
+		return false
+	case "manufacturing_location":
+		return p.ManufacturingLocation == value
+	default:
+		return p.DisposalMethod == value
+	}
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+
+	"ecoscan.com/logic"
+	"ecoscan.com/repo"
+)
+
+// ScoreStore serves scores like jobs.Rescorer does: a product without a stored
+// score is scored with the current rulebook and the score is kept
+type ScoreStore struct {
+	d *Data
+}
+
+// AttachPackaging fills in the stored packaging components of products
+func (s *ScoreStore) AttachPackaging(_ context.Context, products []repo.Product) error {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	for i := range products {
+		products[i].PackagingComponents = s.d.products[products[i].ID].PackagingComponents
+	}
+	return nil
+}
+
+func (s *ScoreStore) Score(ctx context.Context, p repo.Product) (repo.Score, error) {
+	scores, err := s.Scores(ctx, []repo.Product{p})
+	if err != nil {
+		return repo.Score{}, err
+	}
+	return scores[p.ID], nil
+}
+
+func (s *ScoreStore) Scores(ctx context.Context, products []repo.Product) (map[int]repo.Score, error) {
+	result := make(map[int]repo.Score, len(products))
+	var unscored []repo.Product
+	s.d.mu.RLock()
+	for _, p := range products {
+		if score, ok := s.d.scores[p.ID]; ok {
+			result[p.ID] = score
+		} else {
+			unscored = append(unscored, p)
+		}
+	}
+	s.d.mu.RUnlock()
+
+	if err := s.AttachPackaging(ctx, unscored); err != nil {
+		return nil, err
+	}
+	for _, p := range unscored {
+		score := logic.Evaluate(p)
+		result[p.ID] = score
+		s.d.SetScore(score)
+	}
+	return result, nil
+}
@@ -0,0 +1,331 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"ecoscan.com/repo"
+)
+
+type UserStore struct {
+	d *Data
+}
+
+func (s *UserStore) Create(_ context.Context, u repo.User) (repo.User, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	u.Email = repo.NormalizeEmail(u.Email)
+	if s.d.emailTaken(u.Email, 0) {
+		return repo.User{}, repo.ErrConflict
+	}
+	if u.Role == "" {
+		u.Role = repo.RoleUser
+	}
+	now := time.Now()
+	u.ID = s.d.id()
+	u.CreatedAt, u.UpdatedAt = now, now
+	s.d.users[u.ID] = u
+	u.PasswordHash = ""
+	return u, nil
+}
+
+func (s *UserStore) ByEmail(_ context.Context, email string) (repo.User, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	email = repo.NormalizeEmail(email)
+	for _, u := range s.d.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return repo.User{}, repo.ErrNotFound
+}
+
+func (s *UserStore) ByID(_ context.Context, id int64) (repo.User, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	u, ok := s.d.users[id]
+	if !ok {
+		return repo.User{}, repo.ErrNotFound
+	}
+	return u, nil
+}
+
+func (s *UserStore) Update(_ context.Context, u repo.User) (repo.User, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	stored, ok := s.d.users[u.ID]
+	if !ok {
+		return repo.User{}, repo.ErrNotFound
+	}
+	u.Email = repo.NormalizeEmail(u.Email)
+	if s.d.emailTaken(u.Email, u.ID) {
+		return repo.User{}, repo.ErrConflict
+	}
+	if stored.Email != u.Email {
+		stored.EmailVerifiedAt = nil
+	}
+	stored.Name, stored.Email, stored.UpdatedAt = u.Name, u.Email, time.Now()
+	s.d.users[u.ID] = stored
+	stored.PasswordHash = ""
+	return stored, nil
+}
+
+func (s *UserStore) UpdatePassword(_ context.Context, id int64, passwordHash string) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	u, ok := s.d.users[id]
+	if !ok {
+		return repo.ErrNotFound
+	}
+	u.PasswordHash, u.UpdatedAt = passwordHash, time.Now()
+	s.d.users[id] = u
+	return nil
+}
+
+func (s *UserStore) VerifyEmail(_ context.Context, id int64, email string) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	u, ok := s.d.users[id]
+	if !ok || u.Email != repo.NormalizeEmail(email) || u.EmailVerifiedAt != nil {
+		return repo.ErrNotFound
+	}
+	now := time.Now()
+	u.EmailVerifiedAt, u.UpdatedAt = &now, now
+	s.d.users[id] = u
+	return nil
+}
+
+func (s *UserStore) ResetPassword(_ context.Context, id int64, oldHash, newHash string) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	u, ok := s.d.users[id]
+	if !ok || u.PasswordHash != oldHash {
+		return repo.ErrNotFound
+	}
+	now := time.Now()
+	if u.EmailVerifiedAt == nil {
+		u.EmailVerifiedAt = &now
+	}
+	u.PasswordHash, u.UpdatedAt = newHash, now
+	s.d.users[id] = u
+	return nil
+}
+
+func (s *UserStore) SetRole(_ context.Context, id int64, role repo.Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("role %q: %w", role, repo.ErrInvalid)
+	}
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	u, ok := s.d.users[id]
+	if !ok {
+		return repo.ErrNotFound
+	}
+	u.Role, u.UpdatedAt = role, time.Now()
+	s.d.users[id] = u
+	return nil
+}
+
+func (s *UserStore) Delete(_ context.Context, id int64) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if _, ok := s.d.users[id]; !ok {
+		return repo.ErrNotFound
+	}
+	delete(s.d.users, id)
+	// what the foreign keys cascade to
+	s.d.requests = slices.DeleteFunc(s.d.requests, func(r repo.ProductRequest) bool { return r.UserID == id })
+	s.d.tokens = slices.DeleteFunc(s.d.tokens, func(t repo.RefreshToken) bool { return t.UserID == id })
+	for sid, session := range s.d.sessions {
+		if session.UserID == id {
+			delete(s.d.sessions, sid)
+		}
+	}
+	for mid, m := range s.d.messages {
+		if m.CreatedBy != nil && *m.CreatedBy == id {
+			m.CreatedBy = nil
+			s.d.messages[mid] = m
+		}
+	}
+	return nil
+}
+
+// emailTaken mirrors the users.email unique constraint, callers hold the lock
+// and pass a normalized email
+func (d *Data) emailTaken(email string, except int64) bool {
+	for id, u := range d.users {
+		if id != except && u.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+type RequestStore struct {
+	d *Data
+}
+
+func (s *RequestStore) Create(_ context.Context, req repo.ProductRequest, points int) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	u, ok := s.d.users[req.UserID]
+	if !ok {
+		return fmt.Errorf("user %d: %w", req.UserID, repo.ErrNotFound)
+	}
+	req.ID = s.d.id()
+	req.Status = "pending"
+	req.CreatedAt = time.Now()
+	s.d.requests = append(s.d.requests, req)
+	u.Points += points
+	s.d.users[u.ID] = u
+	return nil
+}
+
+type TokenStore struct {
+	d *Data
+}
+
+func (s *TokenStore) Save(_ context.Context, session repo.Session, tokenHash string) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if _, ok := s.d.sessions[session.ID]; ok {
+		return repo.ErrConflict
+	}
+	now := time.Now()
+	session.CreatedAt, session.LastUsedAt, session.RevokedAt = now, now, nil
+	s.d.sessions[session.ID] = session
+	s.d.tokens = append(s.d.tokens, repo.RefreshToken{
+		ID:        s.d.id(),
+		UserID:    session.UserID,
+		TokenHash: tokenHash,
+		Family:    session.ID,
+		ExpiresAt: session.ExpiresAt,
+		CreatedAt: now,
+	})
+	return nil
+}
+
+func (s *TokenStore) Rotate(_ context.Context, tokenHash, nextHash string, expiresAt time.Time, userAgent, ip string) (repo.RefreshToken, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	i := slices.IndexFunc(s.d.tokens, func(t repo.RefreshToken) bool { return t.TokenHash == tokenHash })
+	if i < 0 {
+		return repo.RefreshToken{}, repo.ErrNotFound
+	}
+	t := s.d.tokens[i]
+	now := time.Now()
+	switch {
+	case t.UsedAt != nil:
+		s.d.revokeSession(t.UserID, t.Family, now)
+		return t, repo.ErrTokenReused
+	case t.RevokedAt != nil:
+		return t, repo.ErrTokenRevoked
+	case now.After(t.ExpiresAt):
+		return t, repo.ErrTokenExpired
+	}
+
+	s.d.tokens[i].UsedAt = &now
+	s.d.tokens = append(s.d.tokens, repo.RefreshToken{
+		ID:        s.d.id(),
+		UserID:    t.UserID,
+		TokenHash: nextHash,
+		Family:    t.Family,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	})
+	if session, ok := s.d.sessions[t.Family]; ok {
+		session.UserAgent, session.IP = userAgent, ip
+		session.LastUsedAt, session.ExpiresAt = now, expiresAt
+		s.d.sessions[t.Family] = session
+	}
+	return t, nil
+}
+
+func (s *TokenStore) Session(_ context.Context, id string) (repo.Session, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	session, ok := s.d.sessions[id]
+	if !ok || !active(session, time.Now()) {
+		return repo.Session{}, repo.ErrNotFound
+	}
+	session.Role = s.d.users[session.UserID].Role
+	return session, nil
+}
+
+func (s *TokenStore) Sessions(_ context.Context, userID int64) ([]repo.Session, error) {
+	s.d.mu.RLock()
+	defer s.d.mu.RUnlock()
+	now := time.Now()
+	sessions := []repo.Session{}
+	for _, session := range s.d.sessions {
+		if session.UserID == userID && active(session, now) {
+			sessions = append(sessions, session)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b repo.Session) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (s *TokenStore) RevokeSession(_ context.Context, userID int64, id string) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if !s.d.revokeSession(userID, id, time.Now()) {
+		return repo.ErrNotFound
+	}
+	return nil
+}
+
+func (s *TokenStore) RevokeSessions(_ context.Context, userID int64, keep string) (int64, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	var n int64
+	now := time.Now()
+	for id := range s.d.sessions {
+		if id != keep && s.d.revokeSession(userID, id, now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *TokenStore) PurgeExpired(_ context.Context) (int64, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	now := time.Now()
+	before := len(s.d.tokens)
+	s.d.tokens = slices.DeleteFunc(s.d.tokens, func(t repo.RefreshToken) bool {
+		return t.ExpiresAt.Before(now)
+	})
+	for id, session := range s.d.sessions {
+		if session.ExpiresAt.Before(now) {
+			delete(s.d.sessions, id)
+		}
+	}
+	return int64(before - len(s.d.tokens)), nil
+}
+
+// revokeSession ends one of the user's active sessions and its tokens,
+// reporting whether there was one. callers hold the lock
+func (d *Data) revokeSession(userID int64, id string, at time.Time) bool {
+	session, ok := d.sessions[id]
+	if !ok || session.UserID != userID || !active(session, at) {
+		return false
+	}
+	session.RevokedAt = &at
+	d.sessions[id] = session
+	for i, t := range d.tokens {
+		if t.Family == id && t.RevokedAt == nil {
+			d.tokens[i].RevokedAt = &at
+		}
+	}
+	return true
+}
+
+func active(s repo.Session, now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
@@ -0,0 +1,18 @@
+package repo
+
+import "time"
+
+// ProductMessage is an editor curated message that takes priority over generated ones
+type ProductMessage struct {
+	ID        int64      `json:"id" db:"id"`
+	ProductID int        `json:"product_id" db:"product_id"`
+	Barcode   string     `json:"barcode" db:"barcode"`
+	Lang      string     `json:"lang" db:"lang"`
+	Message   string     `json:"message" db:"message"`
+	Priority  int        `json:"priority" db:"priority"`
+	StartsAt  time.Time  `json:"starts_at" db:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at" db:"ends_at"`
+	CreatedBy *int64     `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"ecoscan.com/repo"
+	"github.com/lib/pq"
+)
+
+const productMessageColumns = `
+	m.id, m.product_id, p.barcode, m.lang, m.message, m.priority,
+	m.starts_at, m.ends_at, m.created_by, m.created_at, m.updated_at`
+
+func (s *ProductStore) CuratedMessage(ctx context.Context, productID int, lang string) (string, error) {
+	var message string
+	query := `
+		SELECT message FROM product_messages
+		WHERE product_id = $1 AND lang = $2
+			AND starts_at <= NOW() AND (ends_at IS NULL OR ends_at > NOW())
+		ORDER BY priority DESC, updated_at DESC
+		LIMIT 1
+	`
+	err := s.DB.GetContext(ctx, &message, query, productID, lang)
+	return message, notFound(err)
+}
+
+func (s *ProductStore) ListMessages(ctx context.Context, filter repo.MessageFilter) ([]repo.ProductMessage, error) {
+	var conditions []string
+	var args []any
+	if filter.ProductID != 0 {
+		args = append(args, filter.ProductID)
+		conditions = append(conditions, fmt.Sprintf("m.product_id = $%d", len(args)))
+	}
+	if filter.Lang != "" {
+		args = append(args, filter.Lang)
+		conditions = append(conditions, fmt.Sprintf("m.lang = $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	messages := []repo.ProductMessage{}
+	query := `SELECT ` + productMessageColumns + `
+		FROM product_messages m
+		JOIN products p ON p.id = m.product_id
+		` + where + `
+		ORDER BY p.barcode, m.lang, m.priority DESC, m.id`
+	err := s.DB.SelectContext(ctx, &messages, query, args...)
+	return messages, err
+}
+
+func (s *ProductStore) GetMessage(ctx context.Context, id int64) (repo.ProductMessage, error) {
+	var message repo.ProductMessage
+	query := `SELECT ` + productMessageColumns + `
+		FROM product_messages m
+		JOIN products p ON p.id = m.product_id
+		WHERE m.id = $1`
+	err := s.DB.GetContext(ctx, &message, query, id)
+	return message, notFound(err)
+}
+
+func (s *ProductStore) CreateMessage(ctx context.Context, m repo.ProductMessage) (int64, error) {
+	var id int64
+	query := `
+		INSERT INTO product_messages (product_id, lang, message, priority, starts_at, ends_at, created_by)
+		VALUES ($1, $2, $3, $4, COALESCE($5, NOW()), $6, $7)
+		RETURNING id
+	`
+	err := s.DB.GetContext(ctx, &id, query,
+		m.ProductID, m.Lang, m.Message, m.Priority, nullTime(m.StartsAt), m.EndsAt, m.CreatedBy)
+	return id, invalid(err)
+}
+
+func (s *ProductStore) UpdateMessage(ctx context.Context, m repo.ProductMessage) error {
+	query := `
+		UPDATE product_messages
+		SET product_id = $1, lang = $2, message = $3, priority = $4,
+			starts_at = COALESCE($5, starts_at), ends_at = $6, updated_at = NOW()
+		WHERE id = $7
+	`
+	res, err := s.DB.ExecContext(ctx, query,
+		m.ProductID, m.Lang, m.Message, m.Priority, nullTime(m.StartsAt), m.EndsAt, m.ID)
+	if err != nil {
+		return invalid(err)
+	}
+	return affected(res)
+}
+
+func (s *ProductStore) DeleteMessage(ctx context.Context, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM product_messages WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+// invalid maps check constraint violations onto repo.ErrInvalid
+func invalid(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if ok && pqErr.Code == "23514" {
+		return fmt.Errorf("%w: %s", repo.ErrInvalid, pqErr.Constraint)
+	}
+	return err
+}
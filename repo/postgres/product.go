@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+
+	"ecoscan.com/repo"
+	"github.com/jmoiron/sqlx"
+)
+
+const productColumns = `id, barcode, name, brand_name, category, sub_category,
+	image_url, price, packaging_material, manufacturing_location, disposal_method`
+
+type ProductStore struct {
+	DB *sqlx.DB
+}
+
+func NewProductStore(db *sqlx.DB) *ProductStore {
+	return &ProductStore{DB: db}
+}
+
+func (s *ProductStore) ByBarcode(ctx context.Context, code string) (repo.Product, error) {
+	var p repo.Product
+	err := s.DB.GetContext(ctx, &p, `SELECT `+productColumns+` FROM products WHERE barcode = $1`, code)
+	return p, notFound(err)
+}
+
+func (s *ProductStore) Alternatives(ctx context.Context, p repo.Product, limit int) ([]repo.Product, error) {
+	var alternatives []repo.Product
+	query := `
+        SELECT ` + productColumns + `
+        FROM products
+        WHERE sub_category = $1 AND id != $2 AND (price < $3 OR packaging_material IN ('glass', 'paper', 'none', 'compostable_paper', 'cardboard'))
+        ORDER BY price DESC, packaging_material ASC
+        LIMIT $4
+    `
+	err := s.DB.SelectContext(ctx, &alternatives, query, p.SubCatergory, p.ID, p.Price, limit)
+	return alternatives, err
+}
+
+func (s *ProductStore) Search(ctx context.Context, query string, limit int) ([]repo.Product, error) {
+	var results []repo.Product
+	similarityThreshold := 0.3
+
+	dbQuery := `
+		SELECT ` + productColumns + `
+		FROM products
+		WHERE similarity(lower(name), $1) > $2 OR lower(name) = $1
+		ORDER BY
+			(lower(name) = $1) DESC,
+			similarity(lower(name), $1) DESC
+		LIMIT $3
+	`
+	err := s.DB.SelectContext(ctx, &results, dbQuery, strings.ToLower(query), similarityThreshold, limit)
+	return results, err
+}
+
+// CategoryRank reports the share of products in the same sub category (and
+// category) that score the same or lower, based on the persisted scores
+func (s *ProductStore) CategoryRank(ctx context.Context, productID int, category string) (repo.CategoryRank, error) {
+	query := `
+		WITH ranked AS (
+			SELECT p.id, p.category, p.sub_category, s.score,
+				cume_dist() OVER (PARTITION BY p.category, p.sub_category ORDER BY s.score) AS sub_percentile,
+				cume_dist() OVER (PARTITION BY p.category ORDER BY s.score) AS category_percentile,
+				rank() OVER (PARTITION BY p.category, p.sub_category ORDER BY s.score DESC) AS sub_rank,
+				COUNT(*) OVER (PARTITION BY p.category, p.sub_category) AS peers,
+				MAX(s.score) OVER (PARTITION BY p.category, p.sub_category) AS best
+			FROM products p
+			JOIN product_scores s ON s.product_id = p.id
+			WHERE p.category IS NOT DISTINCT FROM $2
+		)
+		SELECT COALESCE(category, '') AS category, COALESCE(sub_category, '') AS sub_category,
+			sub_percentile, category_percentile, sub_rank, peers,
+			(score = best AND peers > 1) AS best_in_category
+		FROM ranked WHERE id = $1
+	`
+	var rank repo.CategoryRank
+	if err := s.DB.GetContext(ctx, &rank, query, productID, category); err != nil {
+		return repo.CategoryRank{}, notFound(err)
+	}
+	rank.Percentile = math.Round(rank.Percentile * 100)
+	rank.CategoryPercentile = math.Round(rank.CategoryPercentile * 100)
+	return rank, nil
+}
+
+func (s *ProductStore) LowConfidence(ctx context.Context, limit int) ([]repo.ScoredProduct, error) {
+	var rows []repo.ScoredProduct
+	query := `
+		SELECT p.id, p.barcode, p.name, p.brand_name, p.category, p.sub_category,
+			p.image_url, p.price, p.packaging_material, p.manufacturing_location, p.disposal_method,
+			s.score, s.confidence, s.breakdown
+		FROM products p
+		JOIN product_scores s ON s.product_id = p.id
+		ORDER BY s.confidence ASC, s.score ASC, p.id
+		LIMIT $1
+	`
+	err := s.DB.SelectContext(ctx, &rows, query, limit)
+	return rows, err
+}
+
+func (s *ProductStore) UnmappedAttributes(ctx context.Context) ([]repo.UnmappedAttribute, error) {
+	query := `
+		SELECT u.kind, u.value, u.first_seen, u.last_seen,
+			(SELECT COUNT(*) FROM products p WHERE
+				CASE u.kind
+					WHEN 'packaging_material' THEN p.packaging_material
+					WHEN 'manufacturing_location' THEN p.manufacturing_location
+					ELSE p.disposal_method
+				END = u.value
+				OR (u.kind = 'packaging_material' AND EXISTS (
+					SELECT 1 FROM product_packaging_components c
+					WHERE c.product_id = p.id AND c.material = u.value
+				))) AS product_count
+		FROM unmapped_attribute_values u
+		ORDER BY product_count DESC, u.last_seen DESC
+	`
+	var rows []repo.UnmappedAttribute
+	err := s.DB.SelectContext(ctx, &rows, query)
+	return rows, err
+}
+
+func (s *ProductStore) ReportUnmapped(ctx context.Context, kind, value string) error {
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO unmapped_attribute_values (kind, value)
+		VALUES ($1, $2)
+		ON CONFLICT (kind, value) DO UPDATE SET last_seen = NOW()
+	`, kind, value)
+	return err
+}
+
+func (s *ProductStore) Upsert(ctx context.Context, p repo.Product) (bool, error) {
+	query := `
+		INSERT INTO products (
+			barcode, name, brand_name, category, sub_category, image_url, price,
+			packaging_material, manufacturing_location, disposal_method
+		)
+		VALUES (
+			:barcode, :name, :brand_name, :category, :sub_category, :image_url, :price,
+			:packaging_material, :manufacturing_location, :disposal_method
+		)
+		ON CONFLICT (barcode) DO UPDATE SET
+			name = COALESCE(NULLIF(EXCLUDED.name, ''), products.name),
+			brand_name = COALESCE(NULLIF(EXCLUDED.brand_name, ''), products.brand_name),
+			category = COALESCE(NULLIF(EXCLUDED.category, ''), products.category),
+			sub_category = COALESCE(NULLIF(EXCLUDED.sub_category, ''), products.sub_category),
+			image_url = COALESCE(NULLIF(EXCLUDED.image_url, ''), products.image_url),
+			price = COALESCE(NULLIF(EXCLUDED.price, 0), products.price),
+			packaging_material = COALESCE(NULLIF(EXCLUDED.packaging_material, ''), products.packaging_material),
+			manufacturing_location = COALESCE(NULLIF(EXCLUDED.manufacturing_location, ''), products.manufacturing_location),
+			disposal_method = COALESCE(NULLIF(EXCLUDED.disposal_method, ''), products.disposal_method)
+		RETURNING (xmax = 0) AS created
+	`
+	rows, err := s.DB.NamedQueryContext(ctx, query, p)
+	if err != nil {
+		return false, invalid(err)
+	}
+	defer rows.Close()
+
+	// xmax is only zero on a freshly inserted row
+	var created bool
+	if rows.Next() {
+		err = rows.Scan(&created)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	return created, err
+}
+
+// notFound maps sqlx's no rows error onto repo.ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return repo.ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"ecoscan.com/repo"
+	"github.com/jmoiron/sqlx"
+)
+
+type RequestStore struct {
+	DB *sqlx.DB
+}
+
+func NewRequestStore(db *sqlx.DB) *RequestStore {
+	return &RequestStore{DB: db}
+}
+
+func (s *RequestStore) Create(ctx context.Context, req repo.ProductRequest, points int) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	// ensure rollback if not committed
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	reqQuery := `INSERT INTO product_requests (barcode, name, brand_name, user_id, image_url,
+            packaging_material, manufacturing_location, disposal_method)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''))`
+	if _, err := tx.ExecContext(ctx, reqQuery, req.Barcode, req.Name, req.BrandName, req.UserID, req.ImageURL,
+		req.PackagingMaterial, req.ManufacturingLocation, req.DisposalMethod); err != nil {
+		return fmt.Errorf("inserting product request: %w", err)
+	}
+
+	pointsQuery := `UPDATE users SET points = points + $1 WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, pointsQuery, points, req.UserID); err != nil {
+		return fmt.Errorf("updating user points: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ecoscan.com/repo"
+	"github.com/jmoiron/sqlx"
+)
+
+const tokenColumns = `id, user_id, token_hash, family, expires_at, created_at, used_at, revoked_at`
+
+const sessionColumns = `id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at`
+
+type TokenStore struct {
+	DB *sqlx.DB
+}
+
+func NewTokenStore(db *sqlx.DB) *TokenStore {
+	return &TokenStore{DB: db}
+}
+
+func (s *TokenStore) Save(ctx context.Context, session repo.Session, tokenHash string) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, user_agent, ip, expires_at) VALUES ($1, $2, $3, $4, $5)
+	`, session.ID, session.UserID, session.UserAgent, session.IP, session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family, token_hash, expires_at) VALUES ($1, $2, $3, $4)
+	`, session.UserID, session.ID, tokenHash, session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *TokenStore) Rotate(ctx context.Context, tokenHash, nextHash string, expiresAt time.Time, userAgent, ip string) (repo.RefreshToken, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return repo.RefreshToken{}, err
+	}
+	defer tx.Rollback()
+
+	// the row lock makes a second exchange of the same token wait and then see it used
+	var t repo.RefreshToken
+	err = tx.GetContext(ctx, &t, `SELECT `+tokenColumns+` FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, tokenHash)
+	if err != nil {
+		return t, notFound(err)
+	}
+
+	if t.UsedAt != nil {
+		// a session that already ended has nothing left to revoke
+		err := revokeSession(ctx, tx, t.UserID, t.Family)
+		if errors.Is(err, repo.ErrNotFound) {
+			err = nil
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return t, err
+		}
+		return t, repo.ErrTokenReused
+	}
+	if t.RevokedAt != nil {
+		return t, repo.ErrTokenRevoked
+	}
+	if time.Now().After(t.ExpiresAt) {
+		return t, repo.ErrTokenExpired
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1`, t.ID); err != nil {
+		return t, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family, token_hash, expires_at) VALUES ($1, $2, $3, $4)
+	`, t.UserID, t.Family, nextHash, expiresAt)
+	if err != nil {
+		return t, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE sessions SET user_agent = $2, ip = $3, last_used_at = NOW(), expires_at = $4 WHERE id = $1
+	`, t.Family, userAgent, ip, expiresAt)
+	if err != nil {
+		return t, err
+	}
+	return t, tx.Commit()
+}
+
+func (s *TokenStore) Session(ctx context.Context, id string) (repo.Session, error) {
+	var session repo.Session
+	err := s.DB.GetContext(ctx, &session, `
+		SELECT `+sessionColumns+`, (SELECT role FROM users WHERE users.id = sessions.user_id) AS role
+		FROM sessions
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	`, id)
+	return session, notFound(err)
+}
+
+func (s *TokenStore) Sessions(ctx context.Context, userID int64) ([]repo.Session, error) {
+	sessions := []repo.Session{}
+	err := s.DB.SelectContext(ctx, &sessions, `
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+	`, userID)
+	return sessions, err
+}
+
+func (s *TokenStore) RevokeSession(ctx context.Context, userID int64, id string) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeSession(ctx, tx, userID, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *TokenStore) RevokeSessions(ctx context.Context, userID int64, keep string) (int64, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL AND expires_at > NOW()
+	`, userID, keep)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND family <> $2 AND revoked_at IS NULL
+	`, userID, keep)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+func (s *TokenStore) PurgeExpired(ctx context.Context) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	// a session expires with its newest token, so it has none left by now
+	_, err = s.DB.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at < NOW()`)
+	return n, err
+}
+
+// revokeSession ends a session and its refresh tokens inside tx
+func revokeSession(ctx context.Context, tx *sqlx.Tx, userID int64, id string) error {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	if err := affected(res); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW() WHERE family = $1 AND revoked_at IS NULL
+	`, id)
+	return err
+}
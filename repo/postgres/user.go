@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"ecoscan.com/repo"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type UserStore struct {
+	DB *sqlx.DB
+}
+
+func NewUserStore(db *sqlx.DB) *UserStore {
+	return &UserStore{DB: db}
+}
+
+func (s *UserStore) Create(ctx context.Context, u repo.User) (repo.User, error) {
+	query := `
+		INSERT INTO users (
+			name, 
+			email, 
+			password_hash, 
+			role, 
+			created_at, 
+			updated_at
+		)
+		VALUES (
+			$1, 
+			$2, 
+			$3, 
+			$4, 
+			NOW(), 
+			NOW()
+		)
+		RETURNING id, name, email, points, role, email_verified_at, created_at, updated_at
+	`
+	if u.Role == "" {
+		u.Role = repo.RoleUser
+	}
+	u.Email = repo.NormalizeEmail(u.Email)
+	var newUser repo.User
+	err := s.DB.GetContext(ctx, &newUser, query, u.Name, u.Email, u.PasswordHash, u.Role)
+	return newUser, conflict(err)
+}
+
+func (s *UserStore) ByEmail(ctx context.Context, email string) (repo.User, error) {
+	var user repo.User
+	err := s.DB.GetContext(ctx, &user, `SELECT * FROM users WHERE email = $1`, repo.NormalizeEmail(email))
+	return user, notFound(err)
+}
+
+func (s *UserStore) ByID(ctx context.Context, id int64) (repo.User, error) {
+	var user repo.User
+	err := s.DB.GetContext(ctx, &user, `SELECT * FROM users WHERE id = $1`, id)
+	return user, notFound(err)
+}
+
+func (s *UserStore) Update(ctx context.Context, u repo.User) (repo.User, error) {
+	query := `
+    UPDATE users 
+    SET name = $1, email = $2, updated_at = NOW(),
+        email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
+    WHERE id = $3
+    RETURNING id, name, email, points, role, email_verified_at, created_at, updated_at
+	`
+	var updated repo.User
+	err := s.DB.GetContext(ctx, &updated, query, u.Name, repo.NormalizeEmail(u.Email), u.ID)
+	return updated, conflict(notFound(err))
+}
+
+func (s *UserStore) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	res, err := s.DB.ExecContext(ctx, `UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2`, passwordHash, id)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+func (s *UserStore) VerifyEmail(ctx context.Context, id int64, email string) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND email = $2 AND email_verified_at IS NULL
+	`, id, repo.NormalizeEmail(email))
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+func (s *UserStore) ResetPassword(ctx context.Context, id int64, oldHash, newHash string) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE users SET password_hash = $3, email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1 AND password_hash = $2
+	`, id, oldHash, newHash)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+func (s *UserStore) SetRole(ctx context.Context, id int64, role repo.Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("role %q: %w", role, repo.ErrInvalid)
+	}
+	res, err := s.DB.ExecContext(ctx, `UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2`, role, id)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+// Delete relies on the foreign keys, sessions, tokens and requests cascade and
+// curated messages keep their text without an author
+func (s *UserStore) Delete(ctx context.Context, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return affected(res)
+}
+
+// conflict maps unique violations onto repo.ErrConflict
+func conflict(err error) error {
+	pqErr, ok := err.(*pq.Error)
+	if ok && pqErr.Code == "23505" {
+		return repo.ErrConflict
+	}
+	return err
+}
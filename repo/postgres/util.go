@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"ecoscan.com/repo"
+)
+
+// affected reports repo.ErrNotFound when an update or delete matched no row
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return repo.ErrNotFound
+	}
+	return nil
+}
+
+// nullTime turns the zero time into NULL so the column default or COALESCE applies
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+var (
+	_ repo.ProductStore = (*ProductStore)(nil)
+	_ repo.UserStore    = (*UserStore)(nil)
+	_ repo.RequestStore = (*RequestStore)(nil)
+	_ repo.TokenStore   = (*TokenStore)(nil)
+)
@@ -3,18 +3,29 @@ package repo
 import "time"
 
 type Product struct {
-	ID                    int     `json:"id" db:"id"`
-	Barcode               string  `json:"barcode" db:"barcode"`
-	Name                  string  `json:"name" db:"name"`
-	BrandName             string  `json:"brand_name" db:"brand_name"`
-	Category              string  `json:"category" db:"category"`
-	SubCatergory          string  `json:"sub_category" db:"sub_category"` 
-	ImageURL              string  `json:"image_url" db:"image_url"`
-	Price                 float32 `json:"price" db:"price"`
-	PackagingMaterial     string  `json:"packaging_material" db:"packaging_material"`
-	ManufacturingLocation string  `json:"manufacturing_location" db:"manufacturing_location"`
-	DisposalMethod        string  `json:"disposal_method" db:"disposal_method"`
-	Score                 int     `json:"score" db:"score"` 
+	ID                    int                  `json:"id" db:"id"`
+	Barcode               string               `json:"barcode" db:"barcode"`
+	Name                  string               `json:"name" db:"name"`
+	BrandName             string               `json:"brand_name" db:"brand_name"`
+	Category              string               `json:"category" db:"category"`
+	SubCatergory          string               `json:"sub_category" db:"sub_category"`
+	ImageURL              string               `json:"image_url" db:"image_url"`
+	Price                 float32              `json:"price" db:"price"`
+	PackagingMaterial     string               `json:"packaging_material" db:"packaging_material"`
+	ManufacturingLocation string               `json:"manufacturing_location" db:"manufacturing_location"`
+	DisposalMethod        string               `json:"disposal_method" db:"disposal_method"`
+	Score                 int                  `json:"score" db:"score"`
+	PackagingComponents   []PackagingComponent `json:"packaging_components,omitempty" db:"-"`
+}
+
+// PackagingComponent is one physical part of a product's packaging
+type PackagingComponent struct {
+	ID         int     `json:"id" db:"id"`
+	ProductID  int     `json:"product_id" db:"product_id"`
+	Component  string  `json:"component" db:"component"`
+	Material   string  `json:"material" db:"material"`
+	MassGrams  float64 `json:"mass_grams" db:"mass_grams"`
+	Recyclable bool    `json:"recyclable" db:"recyclable"`
 }
 
 type ProductRequest struct {
@@ -26,4 +37,9 @@ type ProductRequest struct {
 	UserID    int64     `json:"user_id" db:"user_id"`
 	Status    string    `json:"status" db:"status"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// optional, taxonomy codes when the submitted value could be mapped
+	PackagingMaterial     string `json:"packaging_material,omitempty" db:"packaging_material"`
+	ManufacturingLocation string `json:"manufacturing_location,omitempty" db:"manufacturing_location"`
+	DisposalMethod        string `json:"disposal_method,omitempty" db:"disposal_method"`
 }
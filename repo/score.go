@@ -1,7 +1,87 @@
 package repo
 
-type Score struct{
-	PackagingScore int `json:"packaging_score" db:"packaging_score"`
-	LocationScore int `json:"" db:"location_score"`
-	DisposalScore int 
-}
\ No newline at end of file
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type Score struct {
+	ProductID      int            `json:"product_id" db:"product_id"`
+	Score          int            `json:"score" db:"score"`
+	PackagingScore int            `json:"packaging_score" db:"packaging_score"`
+	LocationScore  int            `json:"location_score" db:"location_score"`
+	DisposalScore  int            `json:"disposal_score" db:"disposal_score"`
+	Breakdown      ScoreBreakdown `json:"breakdown" db:"breakdown"`
+	Confidence     float64        `json:"confidence" db:"confidence"`
+	Version        string         `json:"scoring_version" db:"scoring_version"`
+	ComputedAt     time.Time      `json:"computed_at" db:"computed_at"`
+}
+
+// ScoreComponent explains one weighted sub-score
+type ScoreComponent struct {
+	Name      string  `json:"name"`
+	Score     int     `json:"score"`
+	Weight    float64 `json:"weight"`
+	Value     string  `json:"value"`
+	Code      string  `json:"code"`
+	Defaulted bool    `json:"defaulted"`
+	Unmapped  bool    `json:"unmapped"`
+	// 1 when the input was known, 0 when defaulted, the known mass share for packaging parts
+	Certainty float64 `json:"certainty"`
+	Reason    string  `json:"reason"`
+	// only set for transport scored from a geocoded location
+	DistanceKm float64 `json:"distance_km,omitempty"`
+	// only set for packaging scored from its components
+	Parts []PartScore `json:"parts,omitempty"`
+}
+
+// PartScore is one packaging component's contribution to the packaging sub-score
+type PartScore struct {
+	Component  string  `json:"component"`
+	Material   string  `json:"material"`
+	Code       string  `json:"code"`
+	MassGrams  float64 `json:"mass_grams"`
+	Recyclable bool    `json:"recyclable"`
+	Score      int     `json:"score"`
+	Share      float64 `json:"share"`
+	Defaulted  bool    `json:"defaulted"`
+	Unmapped   bool    `json:"unmapped"`
+}
+
+// ScoreBreakdown is stored as JSONB next to the score it explains
+type ScoreBreakdown []ScoreComponent
+
+func (b ScoreBreakdown) Value() (driver.Value, error) {
+	if b == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(b)
+}
+
+func (b *ScoreBreakdown) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*b = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, b)
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	default:
+		return fmt.Errorf("cannot scan %T into ScoreBreakdown", src)
+	}
+}
+
+// CategoryRank places a product's stored score among its peers, so a 55 for a
+// soft drink can be read against other soft drinks rather than detergents
+type CategoryRank struct {
+	Category           string  `json:"category" db:"category"`
+	SubCategory        string  `json:"sub_category" db:"sub_category"`
+	Percentile         float64 `json:"percentile" db:"sub_percentile"`
+	CategoryPercentile float64 `json:"category_percentile" db:"category_percentile"`
+	Rank               int     `json:"rank" db:"sub_rank"`
+	Peers              int     `json:"peers" db:"peers"`
+	BestInCategory     bool    `json:"best_in_category" db:"best_in_category"`
+}
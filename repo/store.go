@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+/* storage interfaces the HTTP handlers depend on. repo/postgres implements them
+on top of sqlx, repo/memory keeps everything in maps for tests and local runs */
+
+var (
+	ErrNotFound = errors.New("not found")
+	// a unique value, e.g. a user's email, is already taken
+	ErrConflict = errors.New("already exists")
+	// the store rejected a value, e.g. a message window that ends before it starts
+	ErrInvalid = errors.New("invalid value")
+
+	// why TokenStore.Rotate refused a refresh token
+	ErrTokenExpired = errors.New("refresh token expired")
+	ErrTokenRevoked = errors.New("refresh token revoked")
+	// the token was exchanged before, its family has been revoked
+	ErrTokenReused = errors.New("refresh token reused")
+)
+
+// ScoredProduct is a product with its stored score's confidence and breakdown
+type ScoredProduct struct {
+	Product
+	Confidence float64        `json:"confidence" db:"confidence"`
+	Breakdown  ScoreBreakdown `json:"breakdown" db:"breakdown"`
+}
+
+// UnmappedAttribute is a reported attribute value with the number of products using it
+type UnmappedAttribute struct {
+	Kind         string    `json:"kind" db:"kind"`
+	Value        string    `json:"value" db:"value"`
+	ProductCount int       `json:"product_count" db:"product_count"`
+	FirstSeen    time.Time `json:"first_seen" db:"first_seen"`
+	LastSeen     time.Time `json:"last_seen" db:"last_seen"`
+}
+
+// MessageFilter narrows ListMessages, empty fields match everything
+type MessageFilter struct {
+	ProductID int
+	Lang      string
+}
+
+type ProductStore interface {
+	// ByBarcode finds a product by its GTIN-14, ErrNotFound when there is none
+	ByBarcode(ctx context.Context, code string) (Product, error)
+	// Alternatives are products of the same sub category that are cheaper or
+	// packed in a greener material
+	Alternatives(ctx context.Context, p Product, limit int) ([]Product, error)
+	// Search matches names fuzzily, an exact name match comes first
+	Search(ctx context.Context, query string, limit int) ([]Product, error)
+	CategoryRank(ctx context.Context, productID int, category string) (CategoryRank, error)
+	// LowConfidence lists scored products with the least certain scores first
+	LowConfidence(ctx context.Context, limit int) ([]ScoredProduct, error)
+	UnmappedAttributes(ctx context.Context) ([]UnmappedAttribute, error)
+	// ReportUnmapped records a value of kind the taxonomy has no code for, a
+	// value seen before only moves its last seen time
+	ReportUnmapped(ctx context.Context, kind, value string) error
+	// Upsert stores p by its barcode, created reports whether it was new. empty
+	// fields of an existing product keep their stored values
+	Upsert(ctx context.Context, p Product) (created bool, err error)
+
+	// CuratedMessage returns the highest priority override active right now
+	CuratedMessage(ctx context.Context, productID int, lang string) (string, error)
+	ListMessages(ctx context.Context, filter MessageFilter) ([]ProductMessage, error)
+	GetMessage(ctx context.Context, id int64) (ProductMessage, error)
+	// CreateMessage saves m, a zero StartsAt means now
+	CreateMessage(ctx context.Context, m ProductMessage) (int64, error)
+	// UpdateMessage rewrites m.ID, a zero StartsAt keeps the stored one
+	UpdateMessage(ctx context.Context, m ProductMessage) error
+	DeleteMessage(ctx context.Context, id int64) error
+}
+
+type UserStore interface {
+	// Create stores a new user, ErrConflict when the email is taken. an empty
+	// role means RoleUser. emails are stored and matched as NormalizeEmail
+	// leaves them, in this and every other method
+	Create(ctx context.Context, u User) (User, error)
+	// ByEmail includes the password hash
+	ByEmail(ctx context.Context, email string) (User, error)
+	ByID(ctx context.Context, id int64) (User, error)
+	// Update saves the user's name and email, ErrConflict when the email is
+	// taken. a changed email is no longer verified
+	Update(ctx context.Context, u User) (User, error)
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+	// VerifyEmail marks email as the user's verified address, ErrNotFound
+	// when it is verified already or no longer theirs
+	VerifyEmail(ctx context.Context, id int64, email string) error
+	// ResetPassword replaces oldHash, ErrNotFound when the password has
+	// changed since. the reset link came by mail, so it verifies the email too
+	ResetPassword(ctx context.Context, id int64, oldHash, newHash string) error
+	// SetRole changes what the user may do, ErrInvalid for an unknown role
+	SetRole(ctx context.Context, id int64, role Role) error
+	// Delete removes the user with their sessions and product requests
+	Delete(ctx context.Context, id int64) error
+}
+
+type RequestStore interface {
+	// Create saves a product request and credits its user with points, both or neither
+	Create(ctx context.Context, req ProductRequest, points int) error
+}
+
+// TokenStore keeps sessions and their refresh tokens. it only ever sees a
+// token's hash, see utils.HashRefreshToken
+type TokenStore interface {
+	// Save starts session s with its first refresh token, valid until s.ExpiresAt
+	Save(ctx context.Context, s Session, tokenHash string) error
+	// Rotate marks the token with tokenHash used, stores nextHash in its session
+	// and records where the session was used from, returning the used token.
+	// ErrNotFound, ErrTokenExpired or ErrTokenRevoked when it can't be exchanged,
+	// and ErrTokenReused after revoking its session when it had been exchanged before
+	Rotate(ctx context.Context, tokenHash, nextHash string, expiresAt time.Time, userAgent, ip string) (RefreshToken, error)
+	// Session returns an active session with its user's current role,
+	// ErrNotFound when it is unknown, revoked or expired
+	Session(ctx context.Context, id string) (Session, error)
+	// Sessions lists a user's active sessions, the most recently used first
+	Sessions(ctx context.Context, userID int64) ([]Session, error)
+	// RevokeSession ends one of the user's sessions and its refresh tokens,
+	// ErrNotFound when the user has no such active session
+	RevokeSession(ctx context.Context, userID int64, id string) error
+	// RevokeSessions ends every active session of the user but keep, which may be
+	// empty, and returns how many it ended
+	RevokeSessions(ctx context.Context, userID int64, keep string) (int64, error)
+	// PurgeExpired deletes expired tokens and sessions and returns how many tokens there were
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// ProductByBarcode looks a scan up under each GTIN-14 it may stand for, in
+// order, see barcode.Code.GTINs
+func ProductByBarcode(ctx context.Context, products ProductStore, gtins []string) (Product, error) {
+	err := ErrNotFound
+	for _, gtin := range gtins {
+		var p Product
+		p, err = products.ByBarcode(ctx, gtin)
+		if !errors.Is(err, ErrNotFound) {
+			return p, err
+		}
+	}
+	return Product{}, err
+}
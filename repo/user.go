@@ -1,13 +1,72 @@
 package repo
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+// Role is what a user may do, each role includes the rights of the ones below it
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator" // curates products, messages and requests
+	RoleAdmin     Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleUser: 1, RoleModerator: 2, RoleAdmin: 3}
+
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// AtLeast reports whether r has the rights of min
+func (r Role) AtLeast(min Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[min]
+}
+
+// NormalizeEmail is the form emails are stored and looked up in, so an
+// address matches itself whatever case it was typed in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
 
 type User struct {
-    ID           int64     `json:"id" db:"id"`
-    Name         string    `json:"name" db:"name"`
-    Email        string    `json:"email" db:"email"`
-    PasswordHash string    `json:"-" db:"password_hash"`
-    Points       int       `json:"points" db:"points"`
-    CreatedAt    time.Time `json:"created_at" db:"created_at"`
-    UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
-}
\ No newline at end of file
+	ID              int64      `json:"id" db:"id"`
+	Name            string     `json:"name" db:"name"`
+	Email           string     `json:"email" db:"email"`
+	PasswordHash    string     `json:"-" db:"password_hash"`
+	Points          int        `json:"points" db:"points"`
+	Role            Role       `json:"role" db:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"` // nil until the mailed link is opened
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// RefreshToken is a stored refresh token. only its hash is kept, the token
+// itself is handed to the client once
+type RefreshToken struct {
+	ID        int64  `json:"id" db:"id"`
+	UserID    int64  `json:"user_id" db:"user_id"`
+	TokenHash string `json:"-" db:"token_hash"`
+	// the session, every token exchanged from the same login
+	Family    string     `json:"family" db:"family"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UsedAt    *time.Time `json:"used_at" db:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at" db:"revoked_at"`
+}
+
+// Session is one login on one device, kept alive by exchanging refresh tokens
+type Session struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     int64      `json:"-" db:"user_id"`
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+	IP         string     `json:"ip" db:"ip"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"-" db:"revoked_at"`
+	// the user's current role, only set by TokenStore.Session
+	Role Role `json:"-" db:"role"`
+}
@@ -1,123 +1,66 @@
 package product
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
+	"context"
 	"log"
-	"math/rand"
-	"net/http"
-	"os"
 	"time"
 
+	"ecoscan.com/llm"
+	"ecoscan.com/prompt"
 	"ecoscan.com/repo"
 )
 
-var lowScoreFallbacks = []string{
-	"বাহ, %s খেলে সত্যিই রিফ্রেশিং লাগে 🌱\nতবে প্লাস্টিক বোতলটা পরিবেশের জন্য ভালো নয়।\nআপনি যদি ক্যান নিতেন, প্রায় ৩০%% বর্জ্য কমানো যেতো।",
-	"%s ব্যবহার করলে মজা আছে 🌱\nকিন্তু এর প্যাকেজিংটা টেকসই নয়।\nআপনি যদি কাচ বা ক্যান বেছে নিতেন, প্রায় ২৫%% সেভ করতে পারতেন।",
-	"%s খাওয়া দারুণ লাগে 🌱\nকিন্তু প্লাস্টিক বোতলটা প্রকৃতির ক্ষতি করে।\nআপনি যদি বিকল্প নিতেন, waste reduction দ্বিগুণ হতো।",
-}
+// how long a message generation may run, it outlives the request that started it
+const generateTimeout = 30 * time.Second
 
-var goodScoreFallbacks = []string{
-	"চমৎকার! %s বেছে নিয়ে আপনি দারুণ কাজ করেছেন 🌱\nএই প্যাকেজিংটা তুলনামূলকভাবে পরিবেশবান্ধব।\nএভাবে প্রায় ৪০%% বর্জ্য কমছে।",
-	"%s নেওয়ায় আপনি পরিবেশকে সাহায্য করছেন 🌱\nএটা সত্যিই অনুপ্রেরণাদায়ক একটি সিদ্ধান্ত।\nএভাবে প্রায় ৩৫%% waste সেভ হচ্ছে।",
-	"%s কিনে আপনি পৃথিবীকে একটু হালকা করেছেন 🌱\nএটা sustainable choice, ভবিষ্যতের জন্য ভালো।\nএভাবে প্রায় ৪৫%% সেভ হচ্ছে।",
+func messageKey(product repo.Product, score int, lang string) llm.CacheKey {
+	return llm.CacheKey{
+		ProductID:     product.ID,
+		ScoreBucket:   llm.ScoreBucket(score),
+		Lang:          lang,
+		PromptVersion: prompt.Version(lang, score),
+	}
 }
 
-func (h *ProductHandler) generateMotivationalMessage(product repo.Product, score int) string {
-    apiKey := os.Getenv("OPENROUTER_API_KEY")
-    if apiKey == "" {
-        return randomScoreAwareFallback(product.Name, score)
-    }
-
-    var prompt string
-    if score < 60 {
-        prompt = fmt.Sprintf(
-			"this is an api call just reply with the actual message"+
-		"Context: The user scanned %s by %s. Eco‑score: %d (low).\n"+
-		"Task: Write exactly 3 lines in casual Bengali.\n"+
-		"- Line 1: Say something nice about the product.\n"+
-		"- Line 2: Point out the environmental issue with its packaging (%s).\n"+
-		"- Line 3: Encourage the user to check the alternative products list shown in the app, "+
-		"and explain they could reduce waste by choosing one of those greener options.\n"+
-		"Tone: Respectful 'আপনি', friendly, motivational, and empowering.\n"+
-		"Always end with 🌱.\n\n"+
-		"Demo (for inspiration, don’t copy exactly): Coconut Cookie খেতে অনেক মজা... তবে Plastic Packaging টা চিন্তার বিষয়। এবার greener হোন, Alternatives গুলো চেক করুন, better অপশন পেলে প্রায় 30%% plastic waste কমাতে পারবেন। আসুন সবাই মিলে পরিচ্ছন্ন বাংলাদেশ 🇧🇩 গড়ি।",
-		product.Name, product.BrandName, score, product.PackagingMaterial,
-)
-
-    } else {
-        prompt = fmt.Sprintf(
-			"this is an api call just reply with the actual message"+
-            "Context: The user scanned %s by %s. Eco‑score: %d (good).\n"+
-                "Task: Write exactly 3 lines in casual Bengali .\n"+
-                "- Use respectful 'আপনি' tone.\n"+
-                "- Line 1: Mention the product name(in english) and celebrate its taste/usage.\n"+
-                "- Line 2: Praise its eco‑friendly packaging or choice.\n"+
-                "- Line 3: Highlight a realistic %% waste saved and encourage continuing.\n"+
-                "Always end with 🌱.\n\n"+
-                "Demo (for inspiration, don’t copy exactly, rewrite in your own way):\n"+
-                "চমৎকার! Aarong Dairy Chocolate Milk এর রিচ চকলেট এর ফ্লেভার অনেক মজা, অনেকের ই পছন্দ এটা। আর এর প্যাকেজিং অনেক sustainable! এটা কিনলে আপনি প্রায় 40%% এর বেশি অপচয় কমালেন। এটা নিশ্চিন্তে কিনতে পারেন। এভাবেই বাংলাদেশ এর পরিবেশ রক্ষায় আপনার অবদান রাখুন।",
-            product.Name, product.BrandName, score,
-        )
-    }
-
-    messages := []map[string]string{
-        {"role": "user", "content": prompt},
-    }
-
-    payload := map[string]interface{}{
-        "model": "meta-llama/llama-4-maverick:free", 
-        "messages": messages,
-    }
-
-    bodyBytes, _ := json.Marshal(payload)
-
-    url := "https://openrouter.ai/api/v1/chat/completions"
-
-    req, _ := http.NewRequest("POST", url, bytes.NewBuffer(bodyBytes))
-    req.Header.Set("Authorization", "Bearer "+apiKey)
-    req.Header.Set("Content-Type", "application/json")
-    req.Header.Set("HTTP-Referer", "https://yourapp.com")
-    req.Header.Set("X-Title", "ecoScanAi")
-
-    client := &http.Client{Timeout: 12 * time.Second}
-    resp, err := client.Do(req)
-    if err != nil {
-        log.Printf("OpenRouter API error: %v", err)
-        return randomScoreAwareFallback(product.Name, score)
-    }
-    defer resp.Body.Close()
-
-    respBody, _ := io.ReadAll(resp.Body)
-    log.Printf("OpenRouter status: %d", resp.StatusCode)
-    log.Println("OpenRouter raw response:", string(respBody))
-
-    var result struct {
-        Choices []struct {
-            Message struct {
-                Content string `json:"content"`
-            } `json:"message"`
-        } `json:"choices"`
-    }
-    if err := json.Unmarshal(respBody, &result); err == nil {
-        if len(result.Choices) > 0 && result.Choices[0].Message.Content != "" {
-            return result.Choices[0].Message.Content
-        }
-    }
-
-    return randomScoreAwareFallback(product.Name, score)
+// motivationalMessage serves the cached message for the product's score bucket.
+// a cold product gets a canned placeholder, the real message is generated
+// when the client opens the message stream.
+func (h *ProductHandler) motivationalMessage(ctx context.Context, product repo.Product, score int, lang string) (message, source string) {
+	if msg, ok := h.Cache.Get(ctx, messageKey(product, score, lang)); ok {
+		return msg, "cache"
+	}
+	return scoreAwareFallback(product, score, lang), "pending"
 }
 
-
-func randomScoreAwareFallback(productName string, score int) string {
-	rand.Seed(time.Now().UnixNano())
-	if score < 50 {
-		msg := lowScoreFallbacks[rand.Intn(len(lowScoreFallbacks))]
-		return fmt.Sprintf(msg, productName)
+// generateMotivationalMessage asks the provider for a message. with an onChunk
+// a streaming provider hands out the message as it is written, any other
+// provider still returns it in one piece.
+func (h *ProductHandler) generateMotivationalMessage(ctx context.Context, product repo.Product, score int, lang string, onChunk func(string)) (string, error) {
+	p, err := prompt.Render(lang, product, score)
+	if err != nil {
+		log.Printf("Could not build prompt for product %s: %v", product.Barcode, err)
+		return "", err
 	}
-	msg := goodScoreFallbacks[rand.Intn(len(goodScoreFallbacks))]
-	return fmt.Sprintf(msg, productName)
+	req := llm.Request{
+		Product: product,
+		Score:   score,
+		Prompt:  p.Text,
+		Lang:    lang,
+	}
+	var msg string
+	if streamer, ok := h.Messages.(llm.Streamer); ok && onChunk != nil {
+		msg, err = streamer.Stream(ctx, req, onChunk)
+	} else {
+		msg, err = h.Messages.Generate(ctx, req)
+	}
+	if err != nil {
+		log.Printf("%s message error: %v", h.Messages.Name(), err)
+		return "", err
+	}
+	return msg, nil
+}
+
+// scoreAwareFallback is the canned message used whenever the provider fails
+func scoreAwareFallback(product repo.Product, score int, lang string) string {
+	return llm.Template{}.Message(product.Barcode, product.Name, score, lang)
 }
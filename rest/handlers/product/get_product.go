@@ -1,117 +1,178 @@
 package product
 
 import (
-    "database/sql"
-    "encoding/json"
-    "errors"
-    "log"
-    "net/http"
-
-    "ecoscan.com/logic"
-    "ecoscan.com/repo"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"ecoscan.com/barcode"
+	"ecoscan.com/i18n"
+	"ecoscan.com/logic"
+	"ecoscan.com/repo"
 )
 
 type ProductResponse struct {
-    Product      repo.Product   `json:"product"`
-    Score        int            `json:"score"`
-    ScoreRating  string         `json:"score_rating"`
-    Alternatives []repo.Product `json:"alternatives"`
-    Message string `json:"message"`
+	Product        repo.Product        `json:"product"`
+	Score          int                 `json:"score"`
+	Confidence     float64             `json:"confidence"`
+	ScoreRating    string              `json:"score_rating"`
+	Breakdown      repo.ScoreBreakdown `json:"breakdown"`
+	CategoryRank   *repo.CategoryRank  `json:"category_rank,omitempty"`
+	ScoringVersion string              `json:"scoring_version"`
+	ScoredAt       time.Time           `json:"scored_at"`
+	ScoredFrom     string              `json:"scored_from"`
+	Alternatives   []repo.Product      `json:"alternatives"`
+	Message        string              `json:"message"`
+	MessageSource  string              `json:"message_source"`
+	// set while Message is a placeholder, an SSE endpoint for the real message
+	MessageStream string `json:"message_stream,omitempty"`
+	// language the rating and message were served in
+	Lang string `json:"lang"`
 }
 
-func getScoreRating(score int) string {
-    if score <= 0 {
-        return "Not Rated"
-    }
-    if score <= 30 {
-        return "High Impact"
-    }
-    if score <= 60 {
-        return "Moderate Impact"
-    }
-    if score <= 80 {
-        return "Good Choice"
-    }
-    return "Excellent Choice"
+// getScoreRating labels a score with the active rulebook's thresholds for the
+// product's category, or as insufficient data when it is mostly guesswork
+func getScoreRating(score repo.Score, product repo.Product, lang string) string {
+	return i18n.T(lang, logic.Rules().Rating(score.Score, score.Confidence, product.Category, product.SubCatergory))
 }
 
 func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
-    w.Header().Set("Content-Type", "application/json")
-
-    var mainProduct repo.Product
-    barcode := r.PathValue("barcode")
-
-    queryMain := `
-        SELECT id, barcode, name, brand_name, category, sub_category,
-               image_url, price, packaging_material, manufacturing_location, disposal_method
-        FROM products WHERE barcode = $1;`
-    err := h.DB.Get(&mainProduct, queryMain, barcode)
-    if err != nil {
-        if errors.Is(err, sql.ErrNoRows) {
-            http.Error(w, `{"message": "Product not found"}`, http.StatusNotFound)
-        } else {
-            log.Printf("Database error fetching product: %v", err)
-            http.Error(w, `{"message": "Internal server error reading product"}`, http.StatusInternalServerError)
-        }
-        return
-    }
-
-    productScore := int(logic.CalculateScore(mainProduct))
-    scoreRating := getScoreRating(productScore)
-    log.Printf("Calculated score for main product %s: %d (%s)", barcode, productScore, scoreRating)
-
-    var alternativesData []repo.Product
-    queryAlt := `
-        SELECT id, barcode, name, brand_name, category, sub_category,
-               image_url, price, packaging_material, manufacturing_location, disposal_method
-        FROM products
-        WHERE sub_category = $1 AND id != $2 AND (price < $3 OR packaging_material IN ('glass', 'paper', 'none', 'compostable_paper', 'cardboard'))
-        ORDER BY price DESC, packaging_material ASC
-        LIMIT 4
-    `
-    err = h.DB.Select(&alternativesData, queryAlt, mainProduct.SubCatergory, mainProduct.ID, mainProduct.Price)
-    if err != nil && !errors.Is(err, sql.ErrNoRows) {
-        log.Printf("Could not find alternatives for product ID %d: %v", mainProduct.ID, err)
-    }
-
-    for i := range alternativesData {
-        altScore := int(logic.CalculateScore(alternativesData[i]))
-        alternativesData[i].Score = altScore
-        log.Printf("Calculated score for alternative %s: %d", alternativesData[i].Barcode, altScore)
-    }
-
-    
-    // if no cache we save into db 
-    var message string
-    if barcode != "894110001015" && barcode != "894110001003" && barcode != "94110001004" {
-    message = h.generateMotivationalMessage(mainProduct, productScore)
-    }
-
-    
-    if barcode == "894110001003"{
-        message= "Bashundhara Paper Towel বেশ ভালো একটি পণ্য। তবে প্লাস্টিকের প্যাকেজিং পরিবেশের জন্য ক্ষতিকর হতে পারে, এটা নিয়ে আমাদের সকলকে সচেতন হতে হবে। আপনি নিচে আমাদের Alternative পণ্যগুলো দেখতে পারেন, যেগুলো পরিবেশবান্ধব এবং এর মাধ্যমে প্রায় ৩৭% মতো বর্জ্য দূষণ কমাতে পারবেন🌱।"
-    }
-    if barcode == "894110001003"{
-        message= "Coca-Cola যেকোনো মুহূর্তকে আর রেফ্রেশিং করে তুলে🌱 এই প্যাকেজিংটা প্লাস্টিক হলেও তুলনামূলকভাবে পরিবেশবান্ধব। আপনি নিচে আমাদের Alternatives পণ্যগুলো দেখতে পারেন। পরিবেশ রক্ষায় এভাব আপনার অবদান রাখুন। 🌱।"
-    }
-    if barcode == "894110001473"{
-        message= "Pepsi প্রতিটি moment-কে করে তোলে আরও lively আর energetic ✨ ক্যান প্যাকেজিং হওয়ায় এটি easily recyclable এবং eco-friendly। আপনার এই conscious choice পরিবেশ রক্ষায় একটি গুরুত্বপূর্ণ step 🌍। আমরা আপনার decision-কে সত্যিই appreciate করি🌱।"
-    }
-
-    if barcode == "894110001004"{
-        message= "Clemon Lemon Soda প্রতিটি sip-কে করে তোলে আরও refreshing 🍋✨ 250ml Can প্যাকেজিং হওয়ায় এটি super easy to recycle এবং eco-friendly choice। আপনার এই cool decision পরিবেশ রক্ষায় একটি ছোট কিন্তু impactful step 🌍। আমরা আপনার conscious lifestyle-কে সত্যিই appreciate করি🌱।"
-    }
-    response := ProductResponse{
-        Product:      mainProduct,
-        Score:        productScore,
-        ScoreRating:  scoreRating,
-        Alternatives: alternativesData,
-        Message: message,
-    }
-
-    w.WriteHeader(http.StatusOK)
-    err = json.NewEncoder(w).Encode(response)
-    if err != nil {
-        log.Printf("Error encoding response: %v", err)
-    }
-}
\ No newline at end of file
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Vary", "Accept-Language")
+
+	scanned := r.PathValue("barcode")
+	lang := i18n.Negotiate(r)
+
+	origin, err := originFromRequest(r)
+	if err != nil {
+		writeError(w, lang, http.StatusBadRequest, "invalid_location", err)
+		return
+	}
+
+	// lookups always use the canonical GTIN-14 form
+	code, err := barcode.Parse(scanned)
+	if err != nil {
+		writeError(w, lang, http.StatusBadRequest, "invalid_barcode", barcodeError(lang, err))
+		return
+	}
+
+	mainProduct, stored, err := h.scoredProduct(r.Context(), code, origin)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, lang, http.StatusNotFound, "product_not_found")
+		} else {
+			log.Printf("Database error fetching product: %v", err)
+			writeError(w, lang, http.StatusInternalServerError, "product_read_error")
+		}
+		return
+	}
+	rules := logic.Rules()
+	scoredFrom := rules.Home().Label()
+	if origin != nil {
+		scoredFrom = origin.Label
+	}
+	productScore := stored.Score
+	mainProduct.Score = productScore
+	scoreRating := getScoreRating(stored, mainProduct, lang)
+
+	// percentiles always compare stored home region scores
+	var categoryRank *repo.CategoryRank
+	if rank, err := h.Products.CategoryRank(r.Context(), mainProduct.ID, mainProduct.Category); err != nil {
+		log.Printf("Could not rank product %s within its category: %v", mainProduct.Barcode, err)
+	} else {
+		categoryRank = &rank
+	}
+	log.Printf("Score for main product %s: %d (%s, %s)", mainProduct.Barcode, productScore, scoreRating, stored.Version)
+
+	alternativesData, err := h.Products.Alternatives(r.Context(), mainProduct, 4)
+	if err != nil {
+		log.Printf("Could not find alternatives for product ID %d: %v", mainProduct.ID, err)
+	}
+
+	if err := h.Scores.AttachPackaging(r.Context(), alternativesData); err != nil {
+		log.Printf("Could not load packaging components for alternatives of product ID %d: %v", mainProduct.ID, err)
+	}
+	altScores, err := h.Scores.Scores(r.Context(), alternativesData)
+	if err != nil {
+		log.Printf("Could not load stored scores for alternatives of product ID %d: %v", mainProduct.ID, err)
+	}
+	for i := range alternativesData {
+		altScore, ok := altScores[alternativesData[i].ID]
+		if origin != nil {
+			altScore = logic.EvaluateFrom(rules, alternativesData[i], *origin)
+		} else if !ok {
+			altScore = logic.Evaluate(alternativesData[i])
+		}
+		alternativesData[i].Score = altScore.Score
+	}
+
+	// an editor's curated message wins, then the cached one. otherwise a canned
+	// placeholder is sent and the client streams the real one from MessageStream
+	message, ok := h.curatedMessage(r.Context(), mainProduct.ID, lang)
+	messageSource := "override"
+	if !ok {
+		message, messageSource = h.motivationalMessage(r.Context(), mainProduct, productScore, lang)
+	}
+	var messageStream string
+	if messageSource == "pending" {
+		q := r.URL.Query()
+		q.Set("lang", lang)
+		messageStream = "/api/v1/products/barcode/" + mainProduct.Barcode + "/message?" + q.Encode()
+	}
+
+	response := ProductResponse{
+		Product:        mainProduct,
+		Score:          productScore,
+		Confidence:     stored.Confidence,
+		ScoreRating:    scoreRating,
+		Breakdown:      stored.Breakdown,
+		CategoryRank:   categoryRank,
+		ScoringVersion: stored.Version,
+		ScoredAt:       stored.ComputedAt,
+		ScoredFrom:     scoredFrom,
+		Alternatives:   alternativesData,
+		Message:        message,
+		MessageSource:  messageSource,
+		MessageStream:  messageStream,
+		Lang:           lang,
+	}
+
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// scoredProduct loads a product with its packaging and the score to show: the
+// stored one, or a live one when the user gave their location. a user
+// location only changes transport, so that score is never persisted.
+func (h *ProductHandler) scoredProduct(ctx context.Context, scanned barcode.Code, origin *logic.Origin) (repo.Product, repo.Score, error) {
+	product, err := repo.ProductByBarcode(ctx, h.Products, scanned.GTINs())
+	if err != nil {
+		return product, repo.Score{}, err
+	}
+	code := product.Barcode
+
+	withPackaging := []repo.Product{product}
+	if err := h.Scores.AttachPackaging(ctx, withPackaging); err != nil {
+		log.Printf("Could not load packaging components for product %s: %v", code, err)
+	}
+	product = withPackaging[0]
+
+	// serve the persisted score so lookups and search always agree
+	score, err := h.Scores.Score(ctx, product)
+	if err != nil {
+		log.Printf("Could not load stored score for product %s, computing it: %v", code, err)
+		score = logic.Evaluate(product)
+	}
+	if origin != nil {
+		score = logic.EvaluateFrom(logic.Rules(), product, *origin)
+	}
+	product.Score = score.Score
+	return product, score, nil
+}
@@ -1,15 +1,40 @@
 package product
 
 import (
-	"github.com/jmoiron/sqlx"
+	"context"
+	"time"
+
+	"ecoscan.com/llm"
+	"ecoscan.com/repo"
 )
 
+// ScoreSource serves persisted scores, jobs.Rescorer in production
+type ScoreSource interface {
+	AttachPackaging(ctx context.Context, products []repo.Product) error
+	Score(ctx context.Context, p repo.Product) (repo.Score, error)
+	Scores(ctx context.Context, products []repo.Product) (map[int]repo.Score, error)
+}
+
+// MessageCache stores generated messages, llm.MessageCache in production
+type MessageCache interface {
+	Get(ctx context.Context, key llm.CacheKey) (string, bool)
+	Fill(key llm.CacheKey, provider string, timeout time.Duration, generate func(ctx context.Context) (string, error)) *llm.Pending
+}
+
 type ProductHandler struct {
-	DB    *sqlx.DB
+	Products repo.ProductStore
+	Requests repo.RequestStore
+	Scores   ScoreSource
+	Messages llm.MessageGenerator
+	Cache    MessageCache
 }
 
-func NewProductHandler(db *sqlx.DB) *ProductHandler {
+func NewProductHandler(products repo.ProductStore, requests repo.RequestStore, scores ScoreSource, messages llm.MessageGenerator, cache MessageCache) *ProductHandler {
 	return &ProductHandler{
-		DB:    db,
+		Products: products,
+		Requests: requests,
+		Scores:   scores,
+		Messages: messages,
+		Cache:    cache,
 	}
 }
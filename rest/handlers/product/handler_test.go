@@ -0,0 +1,297 @@
+package product
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ecoscan.com/llm"
+	"ecoscan.com/repo"
+	"ecoscan.com/repo/memory"
+	"ecoscan.com/rest/middlewares"
+	"ecoscan.com/utils"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("PORT", "8080")
+	os.Setenv("DATABASE_URL", "postgres://localhost/ecoscan_test")
+	os.Setenv("JWT_SECRET_KEY", "test-secret")
+	os.Exit(m.Run())
+}
+
+// testServer is the product API on in-memory stores
+type testServer struct {
+	mux  *http.ServeMux
+	data *memory.Data
+}
+
+func newTestServer(t *testing.T, messages llm.MessageGenerator) *testServer {
+	t.Helper()
+	d := memory.New()
+	middlewares.SetSessions(d.Tokens())
+	t.Cleanup(func() { middlewares.SetSessions(nil) })
+
+	h := NewProductHandler(d.Products(), d.Requests(), d.Scores(), messages, llm.NewMemoryCache(time.Hour))
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux, middlewares.NewManager())
+	return &testServer{mux: mux, data: d}
+}
+
+func (s *testServer) do(t *testing.T, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Accept-Language", "en")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// login creates a user with role and an active session, returning its access token
+func (s *testServer) login(t *testing.T, role repo.Role) string {
+	t.Helper()
+	ctx := context.Background()
+	u, err := s.data.Users().Create(ctx, repo.User{Name: "Test", Email: string(role) + "@example.com", Role: role})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID, err := utils.GenerateSessionID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := repo.Session{ID: sessionID, UserID: u.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.data.Tokens().Save(ctx, session, "hash-"+sessionID); err != nil {
+		t.Fatal(err)
+	}
+	token, err := utils.GenerateAccessToken(u.ID, sessionID, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+var (
+	bottle = repo.Product{
+		Barcode: "04006381333931", Name: "Spring Water", BrandName: "Aqua",
+		Category: "beverages", SubCatergory: "water", Price: 20,
+		PackagingMaterial: "plastic", ManufacturingLocation: "dhaka", DisposalMethod: "recyclable",
+	}
+	jar = repo.Product{
+		Barcode: "00012345678905", Name: "Spring Water Glass", BrandName: "Aqua",
+		Category: "beverages", SubCatergory: "water", Price: 15,
+		PackagingMaterial: "glass", ManufacturingLocation: "dhaka", DisposalMethod: "recyclable",
+	}
+)
+
+func TestGetProduct(t *testing.T) {
+	s := newTestServer(t, llm.Template{})
+	s.data.AddProduct(bottle)
+	s.data.AddProduct(jar)
+
+	// the EAN-13 form of a stored GTIN-14 finds it
+	rec := s.do(t, "GET", "/api/v1/products/barcode/4006381333931", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got ProductResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Product.Barcode != bottle.Barcode || got.Score == 0 || len(got.Breakdown) != 3 {
+		t.Errorf("got product %s with score %d and %d components", got.Product.Barcode, got.Score, len(got.Breakdown))
+	}
+	if len(got.Alternatives) != 1 || got.Alternatives[0].Barcode != jar.Barcode {
+		t.Errorf("alternatives = %+v, want the glass bottle", got.Alternatives)
+	}
+	if got.MessageSource != "pending" || got.MessageStream == "" || got.Lang != "en" {
+		t.Errorf("cold product got message source %q, stream %q, lang %q", got.MessageSource, got.MessageStream, got.Lang)
+	}
+
+	for path, want := range map[string]int{
+		"/api/v1/products/barcode/4006381333932": http.StatusBadRequest,
+		"/api/v1/products/barcode/abc":           http.StatusBadRequest,
+		"/api/v1/products/barcode/5901234123457": http.StatusNotFound,
+	} {
+		if rec := s.do(t, "GET", path, "", nil); rec.Code != want {
+			t.Errorf("GET %s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	s := newTestServer(t, llm.Template{})
+	s.data.AddProduct(bottle)
+	s.data.AddProduct(jar)
+
+	rec := s.do(t, "GET", "/api/v1/products/search?q=spring+water", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got []repo.Product
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// an exact name match is the only result
+	if len(got) != 1 || got[0].Barcode != bottle.Barcode || got[0].Score == 0 {
+		t.Errorf("got %+v, want only the scored exact match", got)
+	}
+
+	if rec := s.do(t, "GET", "/api/v1/products/search", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("search without q: status %d", rec.Code)
+	}
+}
+
+func TestProductMessages(t *testing.T) {
+	s := newTestServer(t, llm.Template{})
+	s.data.AddProduct(bottle)
+	moderator := s.login(t, repo.RoleModerator)
+
+	input := ProductMessageInput{Barcode: "4006381333931", Lang: "en", Message: "Refill it 🌱", Priority: 10}
+	if rec := s.do(t, "POST", "/api/v1/admin/product-messages", "", input); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous create: status %d", rec.Code)
+	}
+	if rec := s.do(t, "POST", "/api/v1/admin/product-messages", s.login(t, repo.RoleUser), input); rec.Code != http.StatusForbidden {
+		t.Errorf("user create: status %d", rec.Code)
+	}
+
+	rec := s.do(t, "POST", "/api/v1/admin/product-messages", moderator, input)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body)
+	}
+	var created repo.ProductMessage
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Barcode != bottle.Barcode || created.CreatedBy == nil {
+		t.Errorf("created %+v", created)
+	}
+
+	// the override is served in place of a generated message
+	rec = s.do(t, "GET", "/api/v1/products/barcode/"+bottle.Barcode, "", nil)
+	var got ProductResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Message != input.Message || got.MessageSource != "override" {
+		t.Errorf("message %q from %q, want the override", got.Message, got.MessageSource)
+	}
+
+	// an 8 digit UPC-E scan finds the product stored under its UPC-A expansion
+	can := bottle
+	can.Barcode, can.Name = "00042100005264", "Sparkling Water"
+	s.data.AddProduct(can)
+	upce := ProductMessageInput{Barcode: "04252614", Lang: "en", Message: "Cans recycle endlessly ♻️"}
+	if rec := s.do(t, "POST", "/api/v1/admin/product-messages", moderator, upce); rec.Code != http.StatusCreated {
+		t.Fatalf("create for UPC-E: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = s.do(t, "GET", "/api/v1/admin/product-messages?barcode=04252614", moderator, nil)
+	var listed []repo.ProductMessage
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].Barcode != can.Barcode || listed[0].Message != upce.Message {
+		t.Errorf("listed %+v for the UPC-E scan, want only the can's message", listed)
+	}
+	if rec := s.do(t, "GET", "/api/v1/admin/product-messages?barcode=5901234123457", moderator, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("list for an unknown product: status %d", rec.Code)
+	}
+
+	input.StartsAt = new(time.Time)
+	*input.StartsAt = time.Now()
+	input.EndsAt = new(time.Time)
+	*input.EndsAt = input.StartsAt.Add(-time.Hour)
+	path := "/api/v1/admin/product-messages/" + strconv.FormatInt(created.ID, 10)
+	if rec := s.do(t, "PUT", path, moderator, input); rec.Code != http.StatusBadRequest {
+		t.Errorf("update ending before it starts: status %d", rec.Code)
+	}
+
+	if rec := s.do(t, "DELETE", path, moderator, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d", rec.Code)
+	}
+	if rec := s.do(t, "DELETE", path, moderator, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete: status %d", rec.Code)
+	}
+}
+
+// lineStreamer streams a fixed message in the given pieces
+type lineStreamer struct {
+	chunks []string
+}
+
+func (l lineStreamer) Name() string { return "test" }
+
+func (l lineStreamer) Generate(context.Context, llm.Request) (string, error) {
+	return strings.Join(l.chunks, ""), nil
+}
+
+func (l lineStreamer) Stream(_ context.Context, _ llm.Request, onChunk func(string)) (string, error) {
+	for _, c := range l.chunks {
+		onChunk(c)
+	}
+	return l.Generate(context.Background(), llm.Request{})
+}
+
+func TestStreamMessage(t *testing.T) {
+	generated := lineStreamer{chunks: []string{
+		"Glass can be refilled and recyc", "led again.\nChoosing it keeps plastic out of rivers.\n",
+		"Thank you for the green choice 🌱",
+	}}
+	s := newTestServer(t, llm.NewGuarded(generated))
+	s.data.AddProduct(jar)
+
+	rec := s.do(t, "GET", "/api/v1/products/barcode/"+jar.Barcode+"/message", "", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	events := rec.Body.String()
+	for _, want := range []string{
+		`event: chunk` + "\n" + `data: {"text":"Glass can be refilled and recycled again.\n"}`,
+		`event: chunk` + "\n" + `data: {"text":"Choosing it keeps plastic out of rivers.\n"}`,
+		`event: message` + "\n" + `data: {"message":"Glass can be refilled and recycled again.\nChoosing it keeps plastic out of rivers.\nThank you for the green choice 🌱","source":"generated","lang":"en"}`,
+	} {
+		if !strings.Contains(events, want) {
+			t.Errorf("stream is missing\n%s\ngot\n%s", want, events)
+		}
+	}
+
+	// the generated message was cached for the next lookup
+	rec = s.do(t, "GET", "/api/v1/products/barcode/"+jar.Barcode, "", nil)
+	var got ProductResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.MessageSource != "cache" || got.MessageStream != "" {
+		t.Errorf("message source %q, stream %q, want the cached message", got.MessageSource, got.MessageStream)
+	}
+}
+
+func TestStreamMessageHoldsBackRejectedLines(t *testing.T) {
+	generated := lineStreamer{chunks: []string{
+		"Glass can be refilled.\nAs an AI, I think ", "this saves 95% waste.\nThanks 🌱",
+	}}
+	s := newTestServer(t, llm.NewGuarded(generated))
+	s.data.AddProduct(jar)
+
+	events := s.do(t, "GET", "/api/v1/products/barcode/"+jar.Barcode+"/message", "", nil).Body.String()
+	if strings.Contains(events, "As an AI") || strings.Contains(events, "95%") {
+		t.Errorf("rejected text reached the client:\n%s", events)
+	}
+	if !strings.Contains(events, `"source":"fallback"`) {
+		t.Errorf("stream did not end with the fallback message:\n%s", events)
+	}
+}
@@ -0,0 +1,41 @@
+package product
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ecoscan.com/barcode"
+	"ecoscan.com/i18n"
+)
+
+// writeError sends {"message": ..., "lang": ...} with the message translated into lang
+func writeError(w http.ResponseWriter, lang string, status int, key string, args ...any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": i18n.T(lang, key, args...),
+		"lang":    lang,
+	})
+}
+
+// barcodeError translates the barcode package's validation errors
+func barcodeError(lang string, err error) string {
+	keys := []struct {
+		err error
+		key string
+	}{
+		{barcode.ErrEmpty, "barcode_empty"},
+		{barcode.ErrNonNumeric, "barcode_non_numeric"},
+		{barcode.ErrLength, "barcode_length"},
+		{barcode.ErrCheckDigit, "barcode_check_digit"},
+		{barcode.ErrInvalidUPCE, "barcode_upce"},
+	}
+	for _, k := range keys {
+		if errors.Is(err, k.err) {
+			return i18n.T(lang, k.key)
+		}
+	}
+	return err.Error()
+}
@@ -0,0 +1,58 @@
+package product
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ecoscan.com/repo"
+)
+
+type LowConfidenceProduct struct {
+	Product    repo.Product `json:"product"`
+	Confidence float64      `json:"confidence"`
+	// breakdown components that were (partly) defaulted
+	Missing []string `json:"missing"`
+}
+
+// ListLowConfidenceProducts lists the products whose scores rest most on defaults, worst first,
+// so the data team knows which attributes to fill in
+func (h *ProductHandler) ListLowConfidenceProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			http.Error(w, `{"message": "limit must be between 1 and 500"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rows, err := h.Products.LowConfidence(r.Context(), limit)
+	if err != nil {
+		log.Printf("Database error listing low confidence products: %v", err)
+		http.Error(w, `{"message": "Could not list low confidence products"}`, http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]LowConfidenceProduct, 0, len(rows))
+	for _, row := range rows {
+		missing := []string{}
+		for _, c := range row.Breakdown {
+			if c.Certainty < 1 {
+				missing = append(missing, c.Name)
+			}
+		}
+		result = append(result, LowConfidenceProduct{
+			Product:    row.Product,
+			Confidence: row.Confidence,
+			Missing:    missing,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,32 @@
+package product
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"ecoscan.com/llm"
+)
+
+// GetMessageProviderStats shows the message provider's circuit, budget and usage
+func (h *ProductHandler) GetMessageProviderStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	reporter, ok := llm.StatsOf(h.Messages)
+	if !ok {
+		// the template provider makes no calls to track
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(llm.ProviderStats{Provider: h.Messages.Name(), Circuit: llm.Closed, History: []llm.DailyUsage{}})
+		return
+	}
+
+	stats, err := reporter.Stats(r.Context())
+	if err != nil {
+		log.Printf("Could not read %s usage: %v", h.Messages.Name(), err)
+		http.Error(w, `{"message": "Could not read provider usage"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,129 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"ecoscan.com/barcode"
+	"ecoscan.com/i18n"
+	"ecoscan.com/repo"
+)
+
+type messageChunk struct {
+	Text string `json:"text"`
+}
+
+type messageEvent struct {
+	Message string `json:"message"`
+	Source  string `json:"source"`
+	Lang    string `json:"lang"`
+}
+
+// eventStream writes server-sent events. generation outlives the handler, so
+// once the handler is done sends are dropped instead of touching the writer.
+type eventStream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	closed  bool
+}
+
+func (s *eventStream) send(event string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Could not encode %s event: %v", event, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload)
+	s.flusher.Flush()
+}
+
+func (s *eventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
+
+// StreamMessage sends the motivational message over server-sent events. a
+// streaming provider sends a "chunk" event for every line that passed the
+// guardrails while it writes, every stream ends with one "message" event
+// holding the message to show, which replaces the chunks: the whole message
+// can still fail a rule no single line does.
+func (h *ProductHandler) StreamMessage(w http.ResponseWriter, r *http.Request) {
+	lang := i18n.Negotiate(r)
+
+	origin, err := originFromRequest(r)
+	if err != nil {
+		writeError(w, lang, http.StatusBadRequest, "invalid_location", err)
+		return
+	}
+	code, err := barcode.Parse(r.PathValue("barcode"))
+	if err != nil {
+		writeError(w, lang, http.StatusBadRequest, "invalid_barcode", barcodeError(lang, err))
+		return
+	}
+
+	product, score, err := h.scoredProduct(r.Context(), code, origin)
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			writeError(w, lang, http.StatusNotFound, "product_not_found")
+		} else {
+			log.Printf("Database error fetching product: %v", err)
+			writeError(w, lang, http.StatusInternalServerError, "product_read_error")
+		}
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("ERROR: response writer does not support streaming")
+		writeError(w, lang, http.StatusInternalServerError, "product_read_error")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(http.StatusOK)
+
+	stream := &eventStream{w: w, flusher: flusher}
+	defer stream.close()
+
+	if msg, ok := h.curatedMessage(r.Context(), product.ID, lang); ok {
+		stream.send("message", messageEvent{Message: msg, Source: "override", Lang: lang})
+		return
+	}
+	key := messageKey(product, score.Score, lang)
+	if msg, ok := h.Cache.Get(r.Context(), key); ok {
+		stream.send("message", messageEvent{Message: msg, Source: "cache", Lang: lang})
+		return
+	}
+
+	// a generation already running for another client is joined and arrives as one event
+	pending := h.Cache.Fill(key, h.Messages.Name(), generateTimeout, func(ctx context.Context) (string, error) {
+		return h.generateMotivationalMessage(ctx, product, score.Score, lang, func(chunk string) {
+			stream.send("chunk", messageChunk{Text: chunk})
+		})
+	})
+	msg, err := pending.Wait(r.Context())
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		stream.send("message", messageEvent{Message: scoreAwareFallback(product, score.Score, lang), Source: "fallback", Lang: lang})
+		return
+	}
+	stream.send("message", messageEvent{Message: msg, Source: "generated", Lang: lang})
+}
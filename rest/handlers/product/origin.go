@@ -0,0 +1,40 @@
+package product
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"ecoscan.com/geo"
+	"ecoscan.com/logic"
+)
+
+// originFromRequest reads the optional user location from ?lat=&lng= or ?near=Sylhet.
+// nil means no location was given and the stored home region score applies.
+func originFromRequest(r *http.Request) (*logic.Origin, error) {
+	q := r.URL.Query()
+	latStr, lngStr, near := q.Get("lat"), q.Get("lng"), q.Get("near")
+
+	if latStr != "" || lngStr != "" {
+		lat, errLat := strconv.ParseFloat(latStr, 64)
+		lng, errLng := strconv.ParseFloat(lngStr, 64)
+		if errLat != nil || errLng != nil {
+			return nil, fmt.Errorf("lat and lng must both be numbers")
+		}
+		point, err := geo.ParsePoint(lat, lng)
+		if err != nil {
+			return nil, err
+		}
+		return &logic.Origin{Point: point, Label: "your location"}, nil
+	}
+
+	if near != "" {
+		place, ok := geo.Resolve(near)
+		if !ok {
+			return nil, fmt.Errorf("unknown location %q", near)
+		}
+		return &logic.Origin{Point: place.Point(), Label: place.Label()}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,219 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecoscan.com/barcode"
+	"ecoscan.com/i18n"
+	"ecoscan.com/repo"
+)
+
+type ProductMessageInput struct {
+	Barcode  string     `json:"barcode"`
+	Lang     string     `json:"lang"`
+	Message  string     `json:"message"`
+	Priority int        `json:"priority"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// curatedMessage returns the highest priority override active right now
+func (h *ProductHandler) curatedMessage(ctx context.Context, productID int, lang string) (string, bool) {
+	message, err := h.Products.CuratedMessage(ctx, productID, lang)
+	if err != nil {
+		if !errors.Is(err, repo.ErrNotFound) {
+			log.Printf("Could not load curated message for product ID %d: %v", productID, err)
+		}
+		return "", false
+	}
+	return message, true
+}
+
+// ListProductMessages lists every override, optionally for one barcode and/or language,
+// including the ones outside their active window
+func (h *ProductHandler) ListProductMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	filter := repo.MessageFilter{Lang: r.URL.Query().Get("lang")}
+	if scanned := r.URL.Query().Get("barcode"); scanned != "" {
+		code, err := barcode.Parse(scanned)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"message": "Invalid barcode: %s"}`, err), http.StatusBadRequest)
+			return
+		}
+		product, err := repo.ProductByBarcode(r.Context(), h.Products, code.GTINs())
+		if err != nil {
+			if errors.Is(err, repo.ErrNotFound) {
+				http.Error(w, `{"message": "Product not found"}`, http.StatusNotFound)
+			} else {
+				log.Printf("Database error fetching product: %v", err)
+				http.Error(w, `{"message": "Internal server error reading product"}`, http.StatusInternalServerError)
+			}
+			return
+		}
+		filter.ProductID = product.ID
+	}
+
+	messages, err := h.Products.ListMessages(r.Context(), filter)
+	if err != nil {
+		log.Printf("Database error listing product messages: %v", err)
+		http.Error(w, `{"message": "Could not list product messages"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(messages)
+}
+
+func (h *ProductHandler) CreateProductMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	message, ok := h.decodeProductMessage(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := extractUserIDFromContext(r.Context())
+	if !ok {
+		log.Println("ERROR: Could not get user ID from context")
+		http.Error(w, `{"message": "User authentication error"}`, http.StatusInternalServerError)
+		return
+	}
+	message.CreatedBy = &userID
+
+	id, err := h.Products.CreateMessage(r.Context(), message)
+	if err != nil {
+		writeProductMessageError(w, err, "creating product message")
+		return
+	}
+
+	h.writeProductMessage(w, r, id, http.StatusCreated)
+}
+
+func (h *ProductHandler) UpdateProductMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"message": "Invalid message id"}`, http.StatusBadRequest)
+		return
+	}
+	message, ok := h.decodeProductMessage(w, r)
+	if !ok {
+		return
+	}
+	message.ID = id
+
+	if err := h.Products.UpdateMessage(r.Context(), message); err != nil {
+		writeProductMessageError(w, err, fmt.Sprintf("updating product message %d", id))
+		return
+	}
+
+	h.writeProductMessage(w, r, id, http.StatusOK)
+}
+
+func (h *ProductHandler) DeleteProductMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"message": "Invalid message id"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Products.DeleteMessage(r.Context(), id); err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, `{"message": "Product message not found"}`, http.StatusNotFound)
+			return
+		}
+		log.Printf("Database error deleting product message %d: %v", id, err)
+		http.Error(w, `{"message": "Could not delete product message"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeProductMessage validates the request body and resolves its barcode,
+// writing the error response itself when it returns false
+func (h *ProductHandler) decodeProductMessage(w http.ResponseWriter, r *http.Request) (repo.ProductMessage, bool) {
+	var input ProductMessageInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, `{"message": "Invalid request body"}`, http.StatusBadRequest)
+		return repo.ProductMessage{}, false
+	}
+
+	input.Lang = strings.ToLower(strings.TrimSpace(input.Lang))
+	input.Message = strings.TrimSpace(input.Message)
+	switch {
+	case !i18n.IsSupported(input.Lang):
+		http.Error(w, `{"message": "lang must be bn or en"}`, http.StatusBadRequest)
+		return repo.ProductMessage{}, false
+	case input.Message == "":
+		http.Error(w, `{"message": "message is required"}`, http.StatusBadRequest)
+		return repo.ProductMessage{}, false
+	case input.EndsAt != nil && input.StartsAt != nil && !input.EndsAt.After(*input.StartsAt):
+		http.Error(w, `{"message": "ends_at must be after starts_at"}`, http.StatusBadRequest)
+		return repo.ProductMessage{}, false
+	}
+
+	code, err := barcode.Parse(input.Barcode)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"message": "Invalid barcode: %s"}`, err), http.StatusBadRequest)
+		return repo.ProductMessage{}, false
+	}
+	product, err := repo.ProductByBarcode(r.Context(), h.Products, code.GTINs())
+	if err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, `{"message": "Product not found"}`, http.StatusNotFound)
+		} else {
+			log.Printf("Database error fetching product: %v", err)
+			http.Error(w, `{"message": "Internal server error reading product"}`, http.StatusInternalServerError)
+		}
+		return repo.ProductMessage{}, false
+	}
+
+	message := repo.ProductMessage{
+		ProductID: product.ID,
+		Lang:      input.Lang,
+		Message:   input.Message,
+		Priority:  input.Priority,
+		EndsAt:    input.EndsAt,
+	}
+	if input.StartsAt != nil {
+		message.StartsAt = *input.StartsAt
+	}
+	return message, true
+}
+
+func writeProductMessageError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		http.Error(w, `{"message": "Product message not found"}`, http.StatusNotFound)
+	case errors.Is(err, repo.ErrInvalid):
+		// a new ends_at can still fall before the stored starts_at
+		http.Error(w, `{"message": "ends_at must be after starts_at"}`, http.StatusBadRequest)
+	default:
+		log.Printf("Database error %s: %v", action, err)
+		http.Error(w, `{"message": "Could not save product message"}`, http.StatusInternalServerError)
+	}
+}
+
+func (h *ProductHandler) writeProductMessage(w http.ResponseWriter, r *http.Request, id int64, status int) {
+	message, err := h.Products.GetMessage(r.Context(), id)
+	if err != nil {
+		log.Printf("Database error reading product message %d: %v", id, err)
+		http.Error(w, `{"message": "Could not read product message"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(message)
+}
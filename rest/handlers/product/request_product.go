@@ -12,7 +12,10 @@ import (
 	"strconv"
 	"strings"
 
+	"ecoscan.com/barcode"
 	"ecoscan.com/config"
+	"ecoscan.com/repo"
+	"ecoscan.com/taxonomy"
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 )
@@ -25,16 +28,37 @@ func (h *ProductHandler) ReqProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	barcode := r.FormValue("barcode")
+	scanned := r.FormValue("barcode")
 	name := r.FormValue("name")
 	brandName := r.FormValue("brandname") // front-end should match this key
 
 	// validate required fields
-	if strings.TrimSpace(barcode) == "" || strings.TrimSpace(name) == "" {
+	if strings.TrimSpace(scanned) == "" || strings.TrimSpace(name) == "" {
 		http.Error(w, "Barcode and Name are required", http.StatusBadRequest)
 		return
 	}
 
+	// requests are stored in the same GTIN-14 form the products table uses
+	code, err := barcode.Normalize(scanned)
+	if err != nil {
+		http.Error(w, "Invalid barcode: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// what the requester knows about the packaging is optional, mapped values
+	// are stored as taxonomy codes and the rest is reported like on import
+	attrs := repo.Product{
+		PackagingMaterial:     strings.TrimSpace(r.FormValue("packaging_material")),
+		ManufacturingLocation: strings.TrimSpace(r.FormValue("manufacturing_location")),
+		DisposalMethod:        strings.TrimSpace(r.FormValue("disposal_method")),
+	}
+	for _, u := range taxonomy.NormalizeProduct(&attrs) {
+		log.Printf("Product request for %s has unmapped %s %q", code, u.Kind, u.Value)
+		if err := h.Products.ReportUnmapped(r.Context(), string(u.Kind), u.Value); err != nil {
+			log.Printf("ERROR reporting unmapped %s %q: %v", u.Kind, u.Value, err)
+		}
+	}
+
 	// safe userID extraction from context
 	userID, ok := extractUserIDFromContext(r.Context())
 	if !ok {
@@ -60,37 +84,21 @@ func (h *ProductHandler) ReqProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// DB transaction
-	tx, err := h.DB.Beginx()
-	if err != nil {
-		log.Printf("ERROR starting db transaction: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
-	}
-	// ensure rollback if not committed
-	defer func() {
-		_ = tx.Rollback()
-	}()
-
-	reqQuery := `INSERT INTO product_requests (barcode, name, brand_name, user_id, image_url)
-        VALUES ($1, $2, $3, $4, $5)`
+	// the request and the user's reward points are saved together
+	request := repo.ProductRequest{
+		Barcode:   code,
+		Name:      name,
+		BrandName: brandName,
+		UserID:    userID,
+		ImageURL:  imageURL,
 
-	if _, err := tx.Exec(reqQuery, barcode, name, brandName, userID, imageURL); err != nil {
-		log.Printf("ERROR inserting product request: %v", err)
-		http.Error(w, "Failed to save request", http.StatusInternalServerError)
-		return
+		PackagingMaterial:     attrs.PackagingMaterial,
+		ManufacturingLocation: attrs.ManufacturingLocation,
+		DisposalMethod:        attrs.DisposalMethod,
 	}
-
-	pointsQuery := `UPDATE users SET points = points + 10 WHERE id = $1`
-	if _, err := tx.Exec(pointsQuery, userID); err != nil {
-		log.Printf("ERROR updating user points: %v", err)
-		http.Error(w, "Failed to update user points", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
-		log.Printf("ERROR committing transaction: %v", err)
-		http.Error(w, "Failed to finalize request", http.StatusInternalServerError)
+	if err := h.Requests.Create(r.Context(), request, 10); err != nil {
+		log.Printf("ERROR saving product request: %v", err)
+		http.Error(w, "Failed to save request", http.StatusInternalServerError)
 		return
 	}
 
@@ -3,6 +3,7 @@ package product
 import (
 	"net/http"
 
+	"ecoscan.com/repo"
 	"ecoscan.com/rest/middlewares"
 )
 
@@ -13,11 +14,71 @@ func (h *ProductHandler) RegisterRoutes(mux *http.ServeMux, mngr *middlewares.Ma
 	)
 
 
+	mux.Handle("GET /api/v1/products/barcode/{barcode}/message",
+		mngr.Chain(http.HandlerFunc(h.StreamMessage)),
+	)
+
 	mux.Handle("GET /api/v1/products/search", mngr.Chain(http.HandlerFunc(h.SearchProductsByName)))
 
 	
 	
 
+	mux.Handle("GET /api/v1/products/low-confidence",
+		mngr.Chain(
+			http.HandlerFunc(h.ListLowConfidenceProducts),
+			middlewares.AuthMiddleware,
+			middlewares.RequireRole(repo.RoleModerator),
+		),
+	)
+
+	mux.Handle("GET /api/v1/taxonomy/unmapped",
+		mngr.Chain(
+			http.HandlerFunc(h.ListUnmappedAttributes),
+			middlewares.AuthMiddleware,
+			middlewares.RequireRole(repo.RoleModerator),
+		),
+	)
+
+	mux.Handle("GET /api/v1/admin/product-messages",
+		mngr.Chain(
+			http.HandlerFunc(h.ListProductMessages),
+			middlewares.AuthMiddleware,
+			middlewares.RequireRole(repo.RoleModerator),
+		),
+	)
+
+	mux.Handle("POST /api/v1/admin/product-messages",
+		mngr.Chain(
+			http.HandlerFunc(h.CreateProductMessage),
+			middlewares.AuthMiddleware,
+			middlewares.RequireRole(repo.RoleModerator),
+		),
+	)
+
+	mux.Handle("PUT /api/v1/admin/product-messages/{id}",
+		mngr.Chain(
+			http.HandlerFunc(h.UpdateProductMessage),
+			middlewares.AuthMiddleware,
+			middlewares.RequireRole(repo.RoleModerator),
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/admin/product-messages/{id}",
+		mngr.Chain(
+			http.HandlerFunc(h.DeleteProductMessage),
+			middlewares.AuthMiddleware,
+			middlewares.RequireRole(repo.RoleModerator),
+		),
+	)
+
+	mux.Handle("GET /api/v1/admin/llm/stats",
+		mngr.Chain(
+			http.HandlerFunc(h.GetMessageProviderStats),
+			middlewares.AuthMiddleware,
+			middlewares.RequireRole(repo.RoleAdmin),
+		),
+	)
+
 	mux.Handle("POST /api/v1/products/request", 
 	mngr.Chain(
 		http.HandlerFunc(h.ReqProduct), 
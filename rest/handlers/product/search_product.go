@@ -6,43 +6,29 @@ import (
 	"net/http"
 	"strings"
 
+	"ecoscan.com/i18n"
 	"ecoscan.com/logic"
 	"ecoscan.com/repo"
 )
 
 func (h *ProductHandler) SearchProductsByName(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	lang := i18n.Negotiate(r)
 
 	query := strings.TrimSpace(r.URL.Query().Get("q"))
 	if query == "" {
-		http.Error(w, `{"message": "Query parameter 'q' is required"}`, http.StatusBadRequest)
+		writeError(w, lang, http.StatusBadRequest, "search_query_required")
 		return
 	}
 	searchQueryLower := strings.ToLower(query)
-
-	var results []repo.Product
-	similarityThreshold := 0.3
 	maxResults := 10
 
-	dbQuery := `
-		SELECT 
-			id, barcode, name, brand_name, category, sub_category, 
-			image_url, price, packaging_material, manufacturing_location, 
-			disposal_method, COALESCE(score, 0) as score 
-		FROM products
-		WHERE similarity(lower(name), $1) > $2 OR lower(name) = $1
-		ORDER BY
-			(lower(name) = $1) DESC,
-			similarity(lower(name), $1) DESC
-		LIMIT $3
-	`
-
-	err := h.DB.Select(&results, dbQuery, searchQueryLower, similarityThreshold, maxResults)
+	results, err := h.Products.Search(r.Context(), query, maxResults)
 
 	if err != nil {
 
 		log.Printf("FATAL SQL ERROR searching '%s': %v", query, err)
-		http.Error(w, `{"message": "Could not perform search"}`, http.StatusInternalServerError)
+		writeError(w, lang, http.StatusInternalServerError, "search_failed")
 		return
 	}
 
@@ -55,8 +41,16 @@ func (h *ProductHandler) SearchProductsByName(w http.ResponseWriter, r *http.Req
 		log.Printf("Returning %d fuzzy matches for query: '%s'", len(results), query)
 	}
 
+	scores, err := h.Scores.Scores(r.Context(), results)
+	if err != nil {
+		log.Printf("Could not load stored scores for search '%s': %v", query, err)
+	}
 	for i := range results {
-		results[i].Score = int(logic.CalculateScore(results[i]))
+		s, ok := scores[results[i].ID]
+		if !ok {
+			s = logic.Evaluate(results[i])
+		}
+		results[i].Score = s.Score
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,39 @@
+package product
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"ecoscan.com/geo"
+	"ecoscan.com/repo"
+	"ecoscan.com/taxonomy"
+)
+
+// ListUnmappedAttributes shows attribute values that scored with a default because the vocabulary didn't know them
+func (h *ProductHandler) ListUnmappedAttributes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	rows, err := h.Products.UnmappedAttributes(r.Context())
+	if err != nil {
+		log.Printf("Database error listing unmapped attributes: %v", err)
+		http.Error(w, `{"message": "Could not list unmapped attributes"}`, http.StatusInternalServerError)
+		return
+	}
+
+	// values the vocabulary has learned since they were reported are done
+	result := make([]repo.UnmappedAttribute, 0, len(rows))
+	for _, row := range rows {
+		kind := taxonomy.Kind(row.Kind)
+		if _, ok := taxonomy.Normalize(kind, row.Value); ok || row.ProductCount == 0 {
+			continue
+		}
+		if _, ok := geo.Resolve(row.Value); ok && kind == taxonomy.ManufacturingLocation {
+			continue
+		}
+		result = append(result, row)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,86 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"ecoscan.com/repo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChangePasswordResponse struct {
+	Message string `json:"message"`
+	// other sessions are signed out, whoever knew the old password may hold one
+	RevokedSessions int64 `json:"revoked_sessions"`
+}
+
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID := sessionFromContext(r)
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < 8 {
+		http.Error(w, "Password length must be 8 minimum", http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkPassword(w, r, userID, req.CurrentPassword) {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("Failed to hash password: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.Users.UpdatePassword(r.Context(), userID, string(hashedPassword)); err != nil {
+		log.Printf("Failed to update password of user %d: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	revoked, err := h.Tokens.RevokeSessions(r.Context(), userID, sessionID)
+	if err != nil {
+		// the password did change, the other sessions just live until they expire
+		log.Printf("Failed to revoke other sessions of user %d: %v", userID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ChangePasswordResponse{
+		Message:         "Password changed",
+		RevokedSessions: revoked,
+	})
+}
+
+// checkPassword compares password with the user's, answering the request when
+// it doesn't match or the user can't be loaded
+func (h *UserHandler) checkPassword(w http.ResponseWriter, r *http.Request, userID int64, password string) bool {
+	user, err := h.Users.ByID(r.Context(), userID)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		log.Printf("Database error finding user %d: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		log.Printf("Wrong password confirming a change for user %d", userID)
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return false
+	}
+	return true
+}
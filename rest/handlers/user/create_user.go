@@ -2,11 +2,12 @@ package user
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
+	"ecoscan.com/i18n"
 	"ecoscan.com/repo"
-	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -25,6 +26,7 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	req.Email = repo.NormalizeEmail(req.Email)
 
 	if req.Email == "" || req.Password == "" || req.Name == "" {
 		http.Error(w, "Name, email and password required", http.StatusBadRequest)
@@ -47,34 +49,24 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		INSERT INTO users (
-			name, 
-			email, 
-			password_hash, 
-			created_at, 
-			updated_at
-		)
-		VALUES (
-			$1, 
-			$2, 
-			$3, 
-			NOW(), 
-			NOW()
-		)
-		RETURNING id, name, email, points, created_at, updated_at
-	`
-	var newUser repo.User
-	err = h.DB.Get(&newUser, query, req.Name, req.Email, string(hashedPassword))
+	newUser, err := h.Users.Create(r.Context(), repo.User{
+		Name:         req.Name,
+		Email:        req.Email,
+		PasswordHash: string(hashedPassword),
+	})
 	if err != nil {
-		pqErr, ok := err.(*pq.Error)
-		if ok && pqErr.Code == "23505" {
+		if errors.Is(err, repo.ErrConflict) {
 			http.Error(w, "Email already in use", http.StatusConflict)
 			return
 		}
 		http.Error(w, "Error occured in database", http.StatusInternalServerError)
 		return
 	}
+
+	// the account works right away, the link only confirms the address
+
+	h.sendVerification(i18n.Negotiate(r), newUser)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(newUser)
@@ -0,0 +1,40 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"ecoscan.com/repo"
+)
+
+type DeleteUserRequest struct {
+	Password string `json:"password"`
+}
+
+// DeleteUser removes the account for good, its sessions and product requests
+// go with it. the password is asked for again so a leaked access token alone
+// can't delete anyone
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userID, _ := sessionFromContext(r)
+
+	var req DeleteUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Password required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.checkPassword(w, r, userID, req.Password) {
+		return
+	}
+
+	err := h.Users.Delete(r.Context(), userID)
+	if err != nil && !errors.Is(err, repo.ErrNotFound) {
+		log.Printf("Failed to delete user %d: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("User %d deleted their account", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,30 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"ecoscan.com/repo"
+)
+
+// GetCurrentUser returns the account the access token belongs to
+func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	userID, _ := sessionFromContext(r)
+
+	user, err := h.Users.ByID(r.Context(), userID)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error finding user %d: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user.PasswordHash = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
@@ -1,14 +1,28 @@
 package user
 
-import "github.com/jmoiron/sqlx"
+import (
+	"ecoscan.com/mail"
+	"ecoscan.com/repo"
+)
 
-// database operations and access to connect
+// stores the user endpoints read and write, and the mailer for verification
+// and password reset links
 type UserHandler struct {
-	DB *sqlx.DB
+	Users  repo.UserStore
+	Tokens repo.TokenStore
+	Mailer mail.Mailer
+
+	// keep the mailed links from being used to flood an inbox or the relay
+	linksByAddress *throttle
+	linksByIP      *throttle
 }
 
-func NewUserHandler(db *sqlx.DB) *UserHandler {
+func NewUserHandler(users repo.UserStore, tokens repo.TokenStore, mailer mail.Mailer) *UserHandler {
 	return &UserHandler{
-		DB: db,
+		Users:          users,
+		Tokens:         tokens,
+		Mailer:         mailer,
+		linksByAddress: newThrottle(linksPerAddress, linkWindow),
+		linksByIP:      newThrottle(linkRequestsPerIP, linkWindow),
 	}
 }
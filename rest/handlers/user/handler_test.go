@@ -0,0 +1,348 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"ecoscan.com/mail"
+	"ecoscan.com/repo"
+	"ecoscan.com/repo/memory"
+	"ecoscan.com/rest/middlewares"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("PORT", "8080")
+	os.Setenv("DATABASE_URL", "postgres://localhost/ecoscan_test")
+	os.Setenv("JWT_SECRET_KEY", "test-secret")
+	os.Exit(m.Run())
+}
+
+// outbox is a mailer that hands every mail to the test
+type outbox chan mail.Message
+
+func (o outbox) Name() string { return "outbox" }
+
+func (o outbox) Send(_ context.Context, m mail.Message) error {
+	o <- m
+	return nil
+}
+
+// next waits for the next mail, mail is sent in the background
+func (o outbox) next(t *testing.T) mail.Message {
+	t.Helper()
+	select {
+	case m := <-o:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("no mail was sent")
+		return mail.Message{}
+	}
+}
+
+// testServer is the user API on in-memory stores
+type testServer struct {
+	mux  *http.ServeMux
+	data *memory.Data
+	mail outbox
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	d := memory.New()
+	middlewares.SetSessions(d.Tokens())
+	t.Cleanup(func() { middlewares.SetSessions(nil) })
+
+	box := make(outbox, 10)
+	h := NewUserHandler(d.Users(), d.Tokens(), box)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux, middlewares.NewManager())
+	return &testServer{mux: mux, data: d, mail: box}
+}
+
+func (s *testServer) do(t *testing.T, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Accept-Language", "en")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body, err)
+	}
+	return v
+}
+
+// register signs a user up and logs them in
+func (s *testServer) register(t *testing.T, name, email, password string) LoginResponse {
+	t.Helper()
+	rec := s.do(t, "POST", "/api/v1/auth/register", "", RegisterRequest{Name: name, Email: email, Password: password})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+	s.mail.next(t) // the verification mail
+
+	rec = s.do(t, "POST", "/api/v1/auth/login", "", LoginRequest{Email: email, Password: password})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", rec.Code, rec.Body)
+	}
+	return decode[LoginResponse](t, rec)
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	s := newTestServer(t)
+	login := s.register(t, "Rahim", "rahim@example.com", "correct horse")
+	if login.AccessToken == "" || login.RefreshToken == "" || login.User.PasswordHash != "" || login.User.Role != repo.RoleUser {
+		t.Errorf("login response %+v", login)
+	}
+
+	rec := s.do(t, "POST", "/api/v1/auth/register", "", RegisterRequest{Name: "Other", Email: "rahim@example.com", Password: "another one"})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("second register with the same email: status %d", rec.Code)
+	}
+	rec = s.do(t, "POST", "/api/v1/auth/register", "", RegisterRequest{Name: "Short", Email: "short@example.com", Password: "short"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("register with a short password: status %d", rec.Code)
+	}
+	rec = s.do(t, "POST", "/api/v1/auth/login", "", LoginRequest{Email: "rahim@example.com", Password: "wrong horse"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("login with a wrong password: status %d", rec.Code)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	s := newTestServer(t)
+	login := s.register(t, "Rahim", "rahim@example.com", "correct horse")
+
+	if rec := s.do(t, "GET", "/api/v1/users/me", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous profile: status %d", rec.Code)
+	}
+	rec := s.do(t, "GET", "/api/v1/users/me", login.AccessToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("profile: status %d: %s", rec.Code, rec.Body)
+	}
+	if me := decode[repo.User](t, rec); me.ID != login.User.ID || me.Email != "rahim@example.com" {
+		t.Errorf("profile %+v", me)
+	}
+
+	name, email := "Rahim Uddin", "rahim.uddin@example.com"
+	rec = s.do(t, "PATCH", "/api/v1/users/me", login.AccessToken, UpdateUserRequest{Name: &name, Email: &email})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", rec.Code, rec.Body)
+	}
+	if me := decode[repo.User](t, rec); me.Name != name || me.Email != email {
+		t.Errorf("updated profile %+v", me)
+	}
+	// the new address gets a verification link
+	if m := s.mail.next(t); m.To != email {
+		t.Errorf("verification mail went to %s", m.To)
+	}
+
+	rec = s.do(t, "PATCH", "/api/v1/users/me", login.AccessToken, map[string]string{"password": "sneaky one"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("update with an unknown field: status %d", rec.Code)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	s := newTestServer(t)
+	login := s.register(t, "Rahim", "rahim@example.com", "correct horse")
+
+	rec := s.do(t, "POST", "/api/v1/auth/refresh", "", RefreshRequest{RefreshToken: login.RefreshToken})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d: %s", rec.Code, rec.Body)
+	}
+	refreshed := decode[RefreshResponse](t, rec)
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	if rec := s.do(t, "GET", "/api/v1/users/me", refreshed.AccessToken, nil); rec.Code != http.StatusOK {
+		t.Errorf("profile with the refreshed access token: status %d", rec.Code)
+	}
+
+	// the old token again means it leaked: the whole session ends
+	rec = s.do(t, "POST", "/api/v1/auth/refresh", "", RefreshRequest{RefreshToken: login.RefreshToken})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("reused refresh token: status %d", rec.Code)
+	}
+	rec = s.do(t, "POST", "/api/v1/auth/refresh", "", RefreshRequest{RefreshToken: refreshed.RefreshToken})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token of a revoked session: status %d", rec.Code)
+	}
+	if rec := s.do(t, "GET", "/api/v1/users/me", refreshed.AccessToken, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token of a revoked session: status %d", rec.Code)
+	}
+
+	if rec := s.do(t, "POST", "/api/v1/auth/refresh", "", RefreshRequest{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("refresh without a token: status %d", rec.Code)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	login := s.register(t, "Rahim", "rahim@example.com", "correct horse")
+
+	if rec := s.do(t, "POST", "/api/v1/auth/logout", login.AccessToken, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("logout: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := s.do(t, "GET", "/api/v1/users/me", login.AccessToken, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after logout: status %d", rec.Code)
+	}
+	rec := s.do(t, "POST", "/api/v1/auth/refresh", "", RefreshRequest{RefreshToken: login.RefreshToken})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token after logout: status %d", rec.Code)
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.register(t, "Admin", "admin@example.com", "correct horse")
+	if err := s.data.Users().SetRole(context.Background(), admin.User.ID, repo.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	member := s.register(t, "Karim", "karim@example.com", "correct horse")
+	rolePath := func(u repo.User) string { return fmt.Sprintf("/api/v1/admin/users/%d/role", u.ID) }
+
+	if rec := s.do(t, "PUT", rolePath(admin.User), member.AccessToken, SetRoleRequest{Role: repo.RoleUser}); rec.Code != http.StatusForbidden {
+		t.Errorf("user setting a role: status %d", rec.Code)
+	}
+
+	// the access token from before the promotion works right away
+	rec := s.do(t, "PUT", rolePath(member.User), admin.AccessToken, SetRoleRequest{Role: repo.RoleAdmin})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("promote: status %d: %s", rec.Code, rec.Body)
+	}
+	if u := decode[repo.User](t, rec); u.Role != repo.RoleAdmin {
+		t.Errorf("promoted user has role %s", u.Role)
+	}
+	if rec := s.do(t, "PUT", rolePath(member.User), member.AccessToken, SetRoleRequest{Role: repo.RoleAdmin}); rec.Code != http.StatusOK {
+		t.Errorf("promoted user with their old token: status %d", rec.Code)
+	}
+
+	// and a demotion takes the rights away on the next request
+	if rec := s.do(t, "PUT", rolePath(member.User), admin.AccessToken, SetRoleRequest{Role: repo.RoleUser}); rec.Code != http.StatusOK {
+		t.Fatalf("demote: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := s.do(t, "PUT", rolePath(admin.User), member.AccessToken, SetRoleRequest{Role: repo.RoleUser}); rec.Code != http.StatusForbidden {
+		t.Errorf("demoted user with their old admin token: status %d", rec.Code)
+	}
+
+	if rec := s.do(t, "PUT", rolePath(admin.User), admin.AccessToken, SetRoleRequest{Role: repo.RoleUser}); rec.Code != http.StatusForbidden {
+		t.Errorf("admin demoting themselves: status %d", rec.Code)
+	}
+	if rec := s.do(t, "PUT", rolePath(member.User), admin.AccessToken, SetRoleRequest{Role: "owner"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown role: status %d", rec.Code)
+	}
+}
+
+func TestEmailCase(t *testing.T) {
+	s := newTestServer(t)
+	login := s.register(t, "Rahim", " Rahim@Example.com", "correct horse")
+	if login.User.Email != "rahim@example.com" {
+		t.Errorf("stored email %q", login.User.Email)
+	}
+
+	rec := s.do(t, "POST", "/api/v1/auth/register", "", RegisterRequest{Name: "Other", Email: "RAHIM@example.com", Password: "another one"})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("register with the same email in other case: status %d", rec.Code)
+	}
+	rec = s.do(t, "POST", "/api/v1/auth/login", "", LoginRequest{Email: "RAHIM@EXAMPLE.COM", Password: "correct horse"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("login with the email in other case: status %d", rec.Code)
+	}
+
+	// changing only the case is no change, the address stays verified
+	if err := s.data.Users().VerifyEmail(context.Background(), login.User.ID, "rahim@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	email := "Rahim@example.COM"
+	rec = s.do(t, "PATCH", "/api/v1/users/me", login.AccessToken, UpdateUserRequest{Email: &email})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", rec.Code, rec.Body)
+	}
+	if me := decode[repo.User](t, rec); me.Email != "rahim@example.com" || me.EmailVerifiedAt == nil {
+		t.Errorf("after a case only change the email is %q, verified at %v", me.Email, me.EmailVerifiedAt)
+	}
+	select {
+	case m := <-s.mail:
+		t.Errorf("a case only change mailed %s", m.Subject)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestForgotPasswordThrottle(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Rahim", "rahim@example.com", "correct horse")
+
+	// an address gets a few links, after that the answer stays the same but no mail goes out
+	for i := range linksPerAddress + 1 {
+		rec := s.do(t, "POST", "/api/v1/auth/password/forgot", "", ForgotPasswordRequest{Email: "Rahim@example.com"})
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+	}
+	for range linksPerAddress {
+		s.mail.next(t)
+	}
+	select {
+	case <-s.mail:
+		t.Error("more reset links than allowed were mailed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// a client is turned away after its share of requests, whichever addresses it asks for
+	for i := linksPerAddress + 1; i < linkRequestsPerIP; i++ {
+		rec := s.do(t, "POST", "/api/v1/auth/password/forgot", "", ForgotPasswordRequest{Email: fmt.Sprintf("nobody%d@example.com", i)})
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+	}
+	rec := s.do(t, "POST", "/api/v1/auth/password/forgot", "", ForgotPasswordRequest{Email: "nobody@example.com"})
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("request over the limit: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestForgotPasswordThrottleIgnoresForwardedFor(t *testing.T) {
+	s := newTestServer(t)
+
+	// a client making up a new X-Forwarded-For for every request is still one client
+	for i := range linkRequestsPerIP + 1 {
+		body, err := json.Marshal(ForgotPasswordRequest{Email: fmt.Sprintf("nobody%d@example.com", i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", "/api/v1/auth/password/forgot", bytes.NewReader(body))
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i+1))
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+
+		want := http.StatusAccepted
+		if i == linkRequestsPerIP {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Fatalf("request %d: status %d, want %d", i+1, rec.Code, want)
+		}
+	}
+}
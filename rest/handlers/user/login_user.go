@@ -1,7 +1,6 @@
 package user
 
 import (
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"log"
@@ -35,11 +34,9 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 
 	// searching db with mail to match
 
-	var user repo.User
-	query := `SELECT * FROM users WHERE email = $1`
-	err := h.DB.Get(&user, query, req.Email)
+	user, err := h.Users.ByEmail(r.Context(), req.Email)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, repo.ErrNotFound) {
 			http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 			return
 		}
@@ -57,9 +54,19 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// every login is a new session, it names the refresh token family and
+	// goes into the access token as sid
+
+	sessionID, err := utils.GenerateSessionID()
+	if err != nil {
+		log.Println("Failed to generate session id: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
 	// generating access tokens
 
-	accessToken, err := utils.GenerateAccessToken(user.ID)
+	accessToken, err := utils.GenerateAccessToken(user.ID, sessionID, user.Role)
 	if err != nil {
         log.Printf("Failed to generate access token: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -75,10 +82,16 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//  saving refresh token into db
+	//  saving the session and its refresh token into db, only the token's hash
 
-	expiryTime := time.Now().Add(time.Hour * 24 * 7)
-    _, err = h.DB.Exec(`INSERT INTO refresh_tokens (user_id, token, expires_at) VALUES ($1, $2, $3)`, user.ID, refreshToken, expiryTime)
+	session := repo.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+    err = h.Tokens.Save(r.Context(), session, utils.HashRefreshToken(refreshToken))
     if err != nil {
         log.Printf("Failed to save refresh token: %v", err)
         http.Error(w, "Internal server error", http.StatusInternalServerError)
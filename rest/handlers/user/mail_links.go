@@ -0,0 +1,65 @@
+package user
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+
+	"ecoscan.com/config"
+	"ecoscan.com/mail"
+	"ecoscan.com/repo"
+	"ecoscan.com/utils"
+)
+
+// pages of the web app the mailed links open, they post the token back to
+// VerifyEmail and ResetPassword
+const (
+	verifyEmailPath   = "/verify-email"
+	resetPasswordPath = "/reset-password"
+)
+
+// a verification link is for one address, changing it or verifying it ends the link
+func verifyState(u repo.User) string {
+	return u.Email
+}
+
+// a reset link ends once the password changes, by the reset or otherwise
+func resetState(u repo.User) string {
+	return u.Email + "\x00" + u.PasswordHash
+}
+
+func (h *UserHandler) sendVerification(lang string, u repo.User) {
+	ttl := config.GetConfig().EmailVerifyTTL
+	h.sendLink(lang, u, mail.VerifyEmail, utils.PurposeVerifyEmail, verifyEmailPath, verifyState(u), ttl)
+}
+
+func (h *UserHandler) sendPasswordReset(lang string, u repo.User) {
+	ttl := config.GetConfig().PasswordResetTTL
+	h.sendLink(lang, u, mail.ResetPassword, utils.PurposeResetPassword, resetPasswordPath, resetState(u), ttl)
+}
+
+// sendLink mails u a link to path carrying a token for purpose. the mail goes
+// out in the background, so a slow relay doesn't hold up the request and
+// ForgotPassword takes as long for unknown emails as for known ones
+func (h *UserHandler) sendLink(lang string, u repo.User, template, purpose, path, state string, ttl time.Duration) {
+	token, err := utils.GenerateEmailToken(purpose, u.ID, state, ttl)
+	if err != nil {
+		log.Printf("Failed to generate %s token for user %d: %v", purpose, u.ID, err)
+		return
+	}
+	link := config.GetConfig().AppBaseURL + path + "?token=" + url.QueryEscape(token)
+
+	msg, err := mail.Render(lang, template, u.Email, mail.Data{Name: u.Name, Link: link}, ttl)
+	if err != nil {
+		log.Printf("Failed to render %s mail: %v", template, err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := h.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %s mail to user %d via %s: %v", template, u.ID, h.Mailer.Name(), err)
+		}
+	}()
+}
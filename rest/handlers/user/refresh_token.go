@@ -0,0 +1,90 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"ecoscan.com/repo"
+	"ecoscan.com/utils"
+)
+
+// how long a refresh token can wait to be exchanged, every exchange starts over
+const refreshTokenTTL = 7 * 24 * time.Hour
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshResponse struct {
+	Message      string `json:"message"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token.
+// each refresh token works once, presenting it again means it leaked, so its
+// session is revoked and the user has to log in again
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Refresh token required", http.StatusBadRequest)
+		return
+	}
+
+	next, err := utils.GenerateRefreshToken()
+	if err != nil {
+		log.Println("Failed to generate refresh token: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	used, err := h.Tokens.Rotate(r.Context(), utils.HashRefreshToken(req.RefreshToken), utils.HashRefreshToken(next),
+		time.Now().Add(refreshTokenTTL), r.UserAgent(), clientIP(r))
+	switch {
+	case errors.Is(err, repo.ErrTokenReused):
+		log.Printf("Refresh token reuse for user %d, revoked session %s", used.UserID, used.Family)
+		http.Error(w, "Refresh token was already used, please log in again", http.StatusUnauthorized)
+		return
+	case errors.Is(err, repo.ErrTokenExpired):
+		http.Error(w, "Refresh token has expired", http.StatusUnauthorized)
+		return
+	case errors.Is(err, repo.ErrNotFound), errors.Is(err, repo.ErrTokenRevoked):
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	case err != nil:
+		log.Printf("Failed to rotate refresh token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// the role is read again on every exchange so the claim stays current for
+	// clients that read it, AuthMiddleware authorizes with the stored role
+	user, err := h.Users.ByID(r.Context(), used.UserID)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", used.UserID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := utils.GenerateAccessToken(user.ID, used.Family, user.Role)
+	if err != nil {
+		log.Printf("Failed to generate access token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RefreshResponse{
+		Message:      "Token refreshed",
+		AccessToken:  accessToken,
+		RefreshToken: next,
+	})
+}
@@ -0,0 +1,130 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecoscan.com/i18n"
+	"ecoscan.com/repo"
+	"ecoscan.com/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// ForgotPassword mails a password reset link. the answer is the same whether
+// or not the email has an account, so it can't be used to find out. a client
+// asking too often is told to wait, an address asked for too often quietly
+// gets no more links for a while, for the same reason
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	// keyed on the peer, a forwarded address would let every request look new
+	ip := remoteIP(r)
+	if ok, retryAfter := h.linksByIP.allow(ip, time.Now()); !ok {
+		log.Printf("Too many password reset requests from %s", ip)
+		tooManyRequests(w, retryAfter)
+		return
+	}
+
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !strings.Contains(req.Email, "@") {
+		http.Error(w, "Email required", http.StatusBadRequest)
+		return
+	}
+	email := repo.NormalizeEmail(req.Email)
+
+	user, err := h.Users.ByEmail(r.Context(), email)
+	switch {
+	case err == nil:
+		if ok, _ := h.linksByAddress.allow("reset "+email, time.Now()); !ok {
+			log.Printf("Too many password reset links for user %d, not sending another", user.ID)
+			break
+		}
+		h.sendPasswordReset(i18n.Negotiate(r), user)
+	case errors.Is(err, repo.ErrNotFound):
+		log.Printf("Password reset asked for unknown email %s", email)
+	default:
+		log.Printf("Database error finding user by email: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "If the email has an account, a reset link is on its way"})
+}
+
+// tooManyRequests tells the client when it may try again
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(retryAfter.Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "Too many requests, please try again later", http.StatusTooManyRequests)
+}
+
+// ResetPassword sets a new password with the token of a reset link and signs
+// the user out everywhere, whoever asked for the reset may not be them
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Token and new password required", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < 8 {
+		http.Error(w, "Password length must be 8 minimum", http.StatusBadRequest)
+		return
+	}
+
+	token, ok := parseLink(w, utils.PurposeResetPassword, req.Token)
+	if !ok {
+		return
+	}
+	user, ok := h.linkUser(w, r, token)
+	if !ok {
+		return
+	}
+	if !token.Matches(resetState(user)) {
+		http.Error(w, "This link has already been used", http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Println("Failed to hash password: ", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	err = h.Users.ResetPassword(r.Context(), user.ID, user.PasswordHash, string(hashedPassword))
+	if errors.Is(err, repo.ErrNotFound) {
+		// the password changed while this request was hashing the new one
+		http.Error(w, "This link has already been used", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to reset password of user %d: %v", user.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	revoked, err := h.Tokens.RevokeSessions(r.Context(), user.ID, "")
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", user.ID, err)
+	}
+	log.Printf("User %d reset their password", user.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ChangePasswordResponse{
+		Message:         "Password reset, please log in again",
+		RevokedSessions: revoked,
+	})
+}
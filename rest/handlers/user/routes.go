@@ -3,6 +3,7 @@ package user
 import (
 	"net/http"
 
+	"ecoscan.com/repo"
 	"ecoscan.com/rest/middlewares"
 )
 
@@ -17,4 +18,85 @@ func (h *UserHandler) RegisterRoutes(mux *http.ServeMux, mngr *middlewares.Manag
 	mngr.Chain(http.HandlerFunc(h.LoginUser),
 		),
 	)
+
+	mux.Handle("POST /api/v1/auth/refresh",
+	mngr.Chain(http.HandlerFunc(h.RefreshToken),
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/verify-email",
+	mngr.Chain(http.HandlerFunc(h.VerifyEmail),
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/password/forgot",
+	mngr.Chain(http.HandlerFunc(h.ForgotPassword),
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/password/reset",
+	mngr.Chain(http.HandlerFunc(h.ResetPassword),
+		),
+	)
+
+	mux.Handle("POST /api/v1/auth/logout",
+	mngr.Chain(http.HandlerFunc(h.Logout),
+		middlewares.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("GET /api/v1/auth/sessions",
+	mngr.Chain(http.HandlerFunc(h.ListSessions),
+		middlewares.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/auth/sessions",
+	mngr.Chain(http.HandlerFunc(h.RevokeSessions),
+		middlewares.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/auth/sessions/{id}",
+	mngr.Chain(http.HandlerFunc(h.RevokeSession),
+		middlewares.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("GET /api/v1/users/me",
+	mngr.Chain(http.HandlerFunc(h.GetCurrentUser),
+		middlewares.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("PATCH /api/v1/users/me",
+	mngr.Chain(http.HandlerFunc(h.UpdateUserInfo),
+		middlewares.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("DELETE /api/v1/users/me",
+	mngr.Chain(http.HandlerFunc(h.DeleteUser),
+		middlewares.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("POST /api/v1/users/me/password",
+	mngr.Chain(http.HandlerFunc(h.ChangePassword),
+		middlewares.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("POST /api/v1/users/me/verify-email",
+	mngr.Chain(http.HandlerFunc(h.ResendVerification),
+		middlewares.AuthMiddleware,
+		),
+	)
+
+	mux.Handle("PUT /api/v1/admin/users/{id}/role",
+	mngr.Chain(http.HandlerFunc(h.SetRole),
+		middlewares.AuthMiddleware,
+		middlewares.RequireRole(repo.RoleAdmin),
+		),
+	)
 }
@@ -0,0 +1,116 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"ecoscan.com/repo"
+)
+
+// SessionResponse is an active session, Current marks the one making the request
+type SessionResponse struct {
+	repo.Session
+	Current bool `json:"current"`
+}
+
+// Logout ends the session of the access token used, its refresh token stops
+// working and so do its other access tokens
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID := sessionFromContext(r)
+
+	err := h.Tokens.RevokeSession(r.Context(), userID, sessionID)
+	if err != nil && !errors.Is(err, repo.ErrNotFound) {
+		log.Printf("Failed to revoke session %s: %v", sessionID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID := sessionFromContext(r)
+
+	sessions, err := h.Tokens.Sessions(r.Context(), userID)
+	if err != nil {
+		log.Printf("Failed to list sessions of user %d: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		response = append(response, SessionResponse{Session: s, Current: s.ID == sessionID})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeSession signs one of the user's devices out, e.g. a lost phone
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, _ := sessionFromContext(r)
+	id := r.PathValue("id")
+
+	err := h.Tokens.RevokeSession(r.Context(), userID, id)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to revoke session %s: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeSessions signs every device out, ?keep_current=true spares the one asking
+func (h *UserHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID := sessionFromContext(r)
+	keep := ""
+	if r.URL.Query().Get("keep_current") == "true" {
+		keep = sessionID
+	}
+
+	n, err := h.Tokens.RevokeSessions(r.Context(), userID, keep)
+	if err != nil {
+		log.Printf("Failed to revoke sessions of user %d: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"revoked": n})
+}
+
+// sessionFromContext returns what AuthMiddleware put in the request context
+func sessionFromContext(r *http.Request) (int64, string) {
+	userID, _ := r.Context().Value("userID").(int64)
+	sessionID, _ := r.Context().Value("sessionID").(string)
+	return userID, sessionID
+}
+
+// clientIP is the address a session was used from, for the user to recognise
+// it. behind a proxy that is the first X-Forwarded-For hop, which the client
+// can fake, so it is only ever shown, never trusted
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+			return ip.String()
+		}
+	}
+	return remoteIP(r)
+}
+
+// remoteIP is the peer the connection came from, the only address a client
+// can't pick for itself. behind a proxy every request shares it
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,60 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ecoscan.com/repo"
+)
+
+type SetRoleRequest struct {
+	Role repo.Role `json:"role"`
+}
+
+// SetRole promotes or demotes a user, admins only. AuthMiddleware reads the
+// role with the session, so it applies to the user's next request
+func (h *UserHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := sessionFromContext(r)
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var req SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Role.Valid() {
+		http.Error(w, "Role must be one of user, moderator or admin", http.StatusBadRequest)
+		return
+	}
+	// an admin demoting themselves could leave nobody to undo it
+	if id == adminID && req.Role != repo.RoleAdmin {
+		http.Error(w, "You can't change your own role", http.StatusForbidden)
+		return
+	}
+
+	err = h.Users.SetRole(r.Context(), id, req.Role)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to set role of user %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.Users.ByID(r.Context(), id)
+	if err != nil {
+		log.Printf("Failed to load user %d: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("User %d set the role of user %d to %s", adminID, id, req.Role)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
@@ -0,0 +1,60 @@
+package user
+
+import (
+	"sync"
+	"time"
+)
+
+// how often a password reset or verification link may be asked for. the
+// counts are kept per process, behind several instances the limits add up
+const (
+	linkWindow        = time.Hour
+	linksPerAddress   = 3  // links of one kind mailed to one address
+	linkRequestsPerIP = 10 // reset link requests from one client, whatever the address
+)
+
+// throttle allows limit events per key within a sliding window
+type throttle struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	events    map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newThrottle(limit int, window time.Duration) *throttle {
+	return &throttle{
+		limit:  limit,
+		window: window,
+		events: map[string][]time.Time{},
+	}
+}
+
+// allow records an event for key unless key already had limit events in the
+// window. when it doesn't, retryAfter is how long until the oldest one expires
+func (t *throttle) allow(key string, now time.Time) (ok bool, retryAfter time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// keys nobody asked for in a whole window are forgotten
+	if now.Sub(t.lastSweep) > t.window {
+		for k, events := range t.events {
+			if now.Sub(events[len(events)-1]) >= t.window {
+				delete(t.events, k)
+			}
+		}
+		t.lastSweep = now
+	}
+
+	events := t.events[key]
+	for len(events) > 0 && now.Sub(events[0]) >= t.window {
+		events = events[1:]
+	}
+	if len(events) >= t.limit {
+		t.events[key] = events
+		return false, events[0].Add(t.window).Sub(now)
+	}
+	t.events[key] = append(events, now)
+	return true, 0
+}
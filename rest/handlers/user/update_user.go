@@ -2,51 +2,77 @@ package user
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 
+	"ecoscan.com/i18n"
 	"ecoscan.com/repo"
 )
 
-func (h *UserHandler) UpdateUserInfo(w http.ResponseWriter, r *http.Request) {
-	uID := r.Context().Value("userID")
+// UpdateUserRequest is a partial update, fields left out keep their value.
+// the password has its own endpoint, see ChangePassword
+type UpdateUserRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
 
-	// decode the body 
-	var reqUpdateUser repo.User
+func (h *UserHandler) UpdateUserInfo(w http.ResponseWriter, r *http.Request) {
+	userID, _ := sessionFromContext(r)
 
-	err := json.NewDecoder(r.Body).Decode(&reqUpdateUser)
-	if err != nil{
+	// decode the body, unknown fields are refused so a misspelt one isn't silently ignored
+	var req UpdateUserRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
 		http.Error(w, "Invalid update user request body", http.StatusBadRequest)
 		return
 	}
 
-	reqUpdateUser.ID = uID.(int64)
-
-	query := `
-    UPDATE users 
-    SET name = $1, email = $2, updated_at = NOW()
-    WHERE id = $3
-    RETURNING id, name, email, points, created_at, updated_at
-	`
-	row := h.DB.QueryRowContext(r.Context(), query, reqUpdateUser.Name,
-	reqUpdateUser.Email,
-	reqUpdateUser.ID,
-	)
-
-	var updatedUser repo.User
-	err = row.Scan(
-		&updatedUser.ID,
-		&updatedUser.Name,
-		&updatedUser.Email,
-        &updatedUser.Points,
-        &updatedUser.CreatedAt,
-        &updatedUser.UpdatedAt,
-	)
-	if err != nil{
+	user, err := h.Users.ByID(r.Context(), userID)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error finding user %d: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	emailBefore := user.Email
+	if req.Name != nil {
+		user.Name = strings.TrimSpace(*req.Name)
+		if user.Name == "" {
+			http.Error(w, "Name can't be empty", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Email != nil {
+		user.Email = repo.NormalizeEmail(*req.Email)
+		if !strings.Contains(user.Email, "@") {
+			http.Error(w, "Invalid email", http.StatusBadRequest)
+			return
+		}
+	}
+
+	updatedUser, err := h.Users.Update(r.Context(), user)
+	if err != nil {
+		if errors.Is(err, repo.ErrConflict) {
+			http.Error(w, "Email already in use", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to update user %d: %v", userID, err)
 		http.Error(w, "Failed to update user", http.StatusInternalServerError)
 		return
 	}
 
+	// a new address has to be verified again
+	if updatedUser.EmailVerifiedAt == nil && updatedUser.Email != emailBefore {
+		h.sendVerification(i18n.Negotiate(r), updatedUser)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updatedUser)
-
 }
@@ -0,0 +1,119 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"ecoscan.com/i18n"
+	"ecoscan.com/repo"
+	"ecoscan.com/utils"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// VerifyEmail confirms the address a verification link was mailed to. it needs
+// no login, the link may be opened on a device the user isn't signed in on
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Token required", http.StatusBadRequest)
+		return
+	}
+
+	token, ok := parseLink(w, utils.PurposeVerifyEmail, req.Token)
+	if !ok {
+		return
+	}
+	user, ok := h.linkUser(w, r, token)
+	if !ok {
+		return
+	}
+	if user.EmailVerifiedAt != nil || !token.Matches(verifyState(user)) {
+		http.Error(w, "This link has already been used", http.StatusBadRequest)
+		return
+	}
+
+	err := h.Users.VerifyEmail(r.Context(), user.ID, user.Email)
+	if errors.Is(err, repo.ErrNotFound) {
+		// verified or changed by a request racing this one
+		http.Error(w, "This link has already been used", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to verify email of user %d: %v", user.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Email verified"})
+}
+
+// ResendVerification mails the current user a new verification link, in the
+// language of the request
+func (h *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, _ := sessionFromContext(r)
+
+	user, err := h.Users.ByID(r.Context(), userID)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Database error finding user %d: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+	if ok, retryAfter := h.linksByAddress.allow("verify "+user.Email, time.Now()); !ok {
+		tooManyRequests(w, retryAfter)
+		return
+	}
+
+	h.sendVerification(i18n.Negotiate(r), user)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Verification email sent"})
+}
+
+// parseLink checks a mailed link's token, answering the request when it is bad
+func parseLink(w http.ResponseWriter, purpose, tokenString string) (utils.EmailToken, bool) {
+	token, err := utils.ParseEmailToken(purpose, tokenString)
+	if errors.Is(err, utils.ErrLinkExpired) {
+		http.Error(w, "This link has expired", http.StatusBadRequest)
+		return token, false
+	}
+	if err != nil {
+		http.Error(w, "Invalid link", http.StatusBadRequest)
+		return token, false
+	}
+	return token, true
+}
+
+// linkUser loads the user a link was mailed to, answering the request when it can't
+func (h *UserHandler) linkUser(w http.ResponseWriter, r *http.Request, token utils.EmailToken) (repo.User, bool) {
+	user, err := h.Users.ByID(r.Context(), token.UserID)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "Invalid link", http.StatusBadRequest)
+		return user, false
+	}
+	if err != nil {
+		log.Printf("Database error finding user %d: %v", token.UserID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return user, false
+	}
+	return user, true
+}
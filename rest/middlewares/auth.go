@@ -2,14 +2,31 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"log" 
 	"net/http"
 	"strconv"
 	"strings"
 
+	"ecoscan.com/repo"
 	"ecoscan.com/utils"
 )
 
+// SessionSource looks up the session an access token belongs to and its
+// user's current role, repo.TokenStore is one
+type SessionSource interface {
+	Session(ctx context.Context, id string) (repo.Session, error)
+}
+
+var sessions SessionSource
+
+// SetSessions makes AuthMiddleware reject access tokens whose session has
+// been revoked or has expired, and authorize with the role the user has now
+// rather than the one in the token
+func SetSessions(s SessionSource) {
+	sessions = s
+}
+
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         w.Header().Set("Content-Type", "application/json") // Ensure JSON errors
@@ -82,7 +99,50 @@ func AuthMiddleware(next http.Handler) http.Handler {
         }
 
 
+        // every access token belongs to a session, which may have been revoked since
+        sessionID, _ := claims["sid"].(string)
+        if sessionID == "" {
+             log.Println("AuthMiddleware: sid claim missing")
+             http.Error(w, `{"message": "Invalid token claims (sid missing)"}`, http.StatusUnauthorized)
+             return
+        }
+        var sessionRole repo.Role
+        if sessions != nil {
+             session, err := sessions.Session(r.Context(), sessionID)
+             if errors.Is(err, repo.ErrNotFound) || (err == nil && session.UserID != userID) {
+                  log.Printf("AuthMiddleware: Session %s of user %d is no longer active", sessionID, userID)
+                  http.Error(w, `{"message": "Session has ended"}`, http.StatusUnauthorized)
+                  return
+             }
+             if err != nil {
+                  log.Printf("AuthMiddleware: Could not load session %s: %v", sessionID, err)
+                  http.Error(w, `{"message": "Internal server error"}`, http.StatusInternalServerError)
+                  return
+             }
+             sessionRole = session.Role
+        }
+
+
+        // tokens from before roles existed carry none, their users are plain users
+        role := repo.RoleUser
+        if v, ok := claims["role"]; ok {
+             name, _ := v.(string)
+             role = repo.Role(name)
+        }
+        // a promotion or demotion counts from the next request, not the next refresh
+        if sessionRole != "" {
+             role = sessionRole
+        }
+        if !role.Valid() {
+             log.Printf("AuthMiddleware: Unknown role %q for user %d", role, userID)
+             http.Error(w, `{"message": "Invalid token claims (role)"}`, http.StatusUnauthorized)
+             return
+        }
+
+
 		ctx := context.WithValue(r.Context(), "userID", userID)
+		ctx = context.WithValue(ctx, "sessionID", sessionID)
+		ctx = context.WithValue(ctx, "role", role)
 		log.Printf("AuthMiddleware: User %d authorized for %s %s", userID, r.Method, r.URL.Path)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+
+	"ecoscan.com/repo"
+)
+
+// RequireRole lets through users with min or a higher role. it reads the role
+// AuthMiddleware put in the context, so it goes after it in a chain:
+//
+//	mngr.Chain(handler, middlewares.AuthMiddleware, middlewares.RequireRole(repo.RoleModerator))
+func RequireRole(min repo.Role) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+
+			role, ok := r.Context().Value("role").(repo.Role)
+			if !ok {
+				log.Printf("RequireRole: no role for %s %s, is AuthMiddleware missing?", r.Method, r.URL.Path)
+				http.Error(w, `{"message": "Authorization header required"}`, http.StatusUnauthorized)
+				return
+			}
+			if !role.AtLeast(min) {
+				log.Printf("RequireRole: User %v with role %s denied %s %s", r.Context().Value("userID"), role, r.Method, r.URL.Path)
+				http.Error(w, `{"message": "You do not have permission to do this"}`, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package taxonomy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"ecoscan.com/geo"
+	"ecoscan.com/repo"
+)
+
+/* controlled vocabulary for the product attributes we score on. data is stored
+and scored by canonical code, synonyms (english and bangla) only exist to map
+whatever a supplier or a user typed onto one of those codes */
+
+type Kind string
+
+const (
+	PackagingMaterial     Kind = "packaging_material"
+	ManufacturingLocation Kind = "manufacturing_location"
+	DisposalMethod        Kind = "disposal_method"
+)
+
+var Kinds = []Kind{PackagingMaterial, ManufacturingLocation, DisposalMethod}
+
+type Term struct {
+	Code     string   `json:"code"`
+	Synonyms []string `json:"synonyms"`
+}
+
+// Unmapped is a non-empty attribute value the vocabulary has no code for
+type Unmapped struct {
+	Kind  Kind   `json:"kind" db:"kind"`
+	Value string `json:"value" db:"value"`
+}
+
+//go:embed vocabulary.json
+var vocabularyJSON []byte
+
+var (
+	terms = map[Kind][]Term{}
+	index = map[Kind]map[string]string{}
+)
+
+func init() {
+	if err := json.Unmarshal(vocabularyJSON, &terms); err != nil {
+		panic("taxonomy: decoding vocabulary: " + err.Error())
+	}
+	for _, kind := range Kinds {
+		idx := map[string]string{}
+		for _, t := range terms[kind] {
+			for _, s := range append([]string{t.Code}, t.Synonyms...) {
+				key := normalizeKey(s)
+				if other, dup := idx[key]; dup && other != t.Code {
+					panic(fmt.Sprintf("taxonomy: %s synonym %q maps to both %s and %s", kind, s, other, t.Code))
+				}
+				idx[key] = t.Code
+			}
+		}
+		index[kind] = idx
+	}
+}
+
+// Normalize maps a free text value to its canonical code. ok is false for
+// empty values and for values the vocabulary doesn't know.
+func Normalize(kind Kind, value string) (code string, ok bool) {
+	key := normalizeKey(value)
+	if key == "" {
+		return "", false
+	}
+	idx := index[kind]
+	if code, ok := idx[key]; ok {
+		return code, true
+	}
+
+	// "Dhaka, Bangladesh" style locations: the broadest part is usually last
+	if kind == ManufacturingLocation {
+		parts := strings.Split(value, ",")
+		for i := len(parts) - 1; i >= 0; i-- {
+			if code, ok := idx[normalizeKey(parts[i])]; ok {
+				return code, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Known reports whether code is a canonical code of kind
+func Known(kind Kind, code string) bool {
+	for _, t := range terms[kind] {
+		if t.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Terms lists the vocabulary for one attribute
+func Terms(kind Kind) []Term {
+	return terms[kind]
+}
+
+// NormalizeProduct rewrites packaging and disposal of p to canonical codes in
+// place. the manufacturing location is kept as typed, scoring resolves it
+// against the gazetteer for a distance first and a code would lose the city.
+// values neither can map are left untouched and returned.
+func NormalizeProduct(p *repo.Product) []Unmapped {
+	var unmapped []Unmapped
+	fields := []struct {
+		kind  Kind
+		value *string
+	}{
+		{PackagingMaterial, &p.PackagingMaterial},
+		{DisposalMethod, &p.DisposalMethod},
+	}
+	for _, f := range fields {
+		if strings.TrimSpace(*f.value) == "" {
+			continue
+		}
+		if code, ok := Normalize(f.kind, *f.value); ok {
+			*f.value = code
+		} else {
+			unmapped = append(unmapped, Unmapped{Kind: f.kind, Value: *f.value})
+		}
+	}
+
+	if loc := p.ManufacturingLocation; strings.TrimSpace(loc) != "" {
+		_, mapped := Normalize(ManufacturingLocation, loc)
+		if _, placed := geo.Resolve(loc); !mapped && !placed {
+			unmapped = append(unmapped, Unmapped{Kind: ManufacturingLocation, Value: loc})
+		}
+	}
+	return unmapped
+}
+
+// lower case, "_" and "-" as spaces, no surrounding punctuation, single spaces
+func normalizeKey(s string) string {
+	s = strings.ToLower(s)
+	s = strings.NewReplacer("_", " ", "-", " ").Replace(s)
+	s = strings.TrimFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"ecoscan.com/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+/* tokens for the links we mail. they are JWTs signed with a key derived from
+JWT_SECRET_KEY, so one can never pass for an access token, and they carry a
+digest of the state they were issued for, e.g. the password hash. acting on
+a link changes that state, which is what makes every link work only once */
+
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+var (
+	ErrLinkExpired = errors.New("link has expired")
+	ErrLinkInvalid = errors.New("invalid link")
+)
+
+// EmailToken is a checked link token, its state is compared with Matches
+type EmailToken struct {
+	UserID int64
+	state  string
+}
+
+// GenerateEmailToken signs a link token for purpose that works for ttl and only
+// while state is what it is now
+func GenerateEmailToken(purpose string, userID int64, state string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"purpose": purpose,
+		"user_id": userID,
+		"state":   stateDigest(state),
+		"exp":     now.Add(ttl).Unix(),
+		"iat":     now.Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(emailTokenKey())
+}
+
+// ParseEmailToken checks a link token's signature, expiry and purpose
+func ParseEmailToken(purpose, tokenString string) (EmailToken, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return emailTokenKey(), nil
+	})
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return EmailToken{}, ErrLinkExpired
+	}
+	if err != nil {
+		return EmailToken{}, ErrLinkInvalid
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	state, _ := claims["state"].(string)
+	if claims["purpose"] != purpose || userID <= 0 || state == "" {
+		return EmailToken{}, ErrLinkInvalid
+	}
+	return EmailToken{UserID: int64(userID), state: state}, nil
+}
+
+// Matches reports whether the token was issued for state
+func (t EmailToken) Matches(state string) bool {
+	return hmac.Equal([]byte(t.state), []byte(stateDigest(state)))
+}
+
+func stateDigest(state string) string {
+	mac := hmac.New(sha256.New, emailTokenKey())
+	mac.Write([]byte(state))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func emailTokenKey() []byte {
+	mac := hmac.New(sha256.New, []byte(config.GetConfig().JWTSecretKey))
+	mac.Write([]byte("ecoscan email links"))
+	return mac.Sum(nil)
+}
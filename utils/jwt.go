@@ -2,17 +2,25 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"time"
 
 	"ecoscan.com/config"
+	"ecoscan.com/repo"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateAccessToken(userID int64) (string, error) {
+// GenerateAccessToken signs a 15 minute token for a user's session, the sid
+// claim lets AuthMiddleware turn it away once the session was revoked. the
+// role is the user's at signing time, AuthMiddleware goes by the current one
+func GenerateAccessToken(userID int64, sessionID string, role repo.Role) (string, error) {
 	cnf := config.GetConfig()
 	claims := jwt.MapClaims{ //this is payload
 		"user_id": userID,
+		"sid":     sessionID,
+		"role":    string(role),
 		"exp":     time.Now().Add(time.Minute * 15).Unix(), //exp in 15 min
 		"iat":     time.Now().Unix(),
 	}
@@ -31,3 +39,19 @@ func GenerateRefreshToken() (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(b), nil
 }
+
+// HashRefreshToken is what refresh_tokens stores in place of the token. the
+// token is 32 random bytes, so a fast hash is enough
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateSessionID names a new login, its refresh token family and the sid of its access tokens
+func GenerateSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}